@@ -13,6 +13,7 @@ type Episode struct {
 	*scanApi.Episode
 	Issue       *download.DigitalComic
 	Filename    string
+	Publication string
 	IssueNumber int
 }
 
@@ -24,10 +25,17 @@ type Story struct {
 	LastIssue  int
 	Issues     []int
 	ToExport   bool
+	// Reprint is set when the story's episodes are reprints. Reprints are kept apart from the original run so
+	// that they don't upset its grouping and part numbers.
+	Reprint bool
 }
 
 func (s *Story) Display() string {
-	return strings.Join([]string{s.Series, s.Title}, " - ")
+	display := strings.Join([]string{s.Series, s.Title}, " - ")
+	if s.Reprint {
+		display += " (Reprint)"
+	}
+	return display
 }
 
 func (s *Story) IssueSummary() string {
@@ -59,6 +67,59 @@ func (s *Story) IssueSummary() string {
 	return strings.Join(progs, ", ")
 }
 
+// ExportOrder decides the order that the episodes of the exported stories appear in
+type ExportOrder int64
+
+const (
+	// OrderAsPublished interleaves the stories as they appeared in the progs, by issue and then page
+	OrderAsPublished ExportOrder = iota
+	// OrderByStory gives each story complete, one after another, starting with the earliest
+	OrderByStory
+	// OrderCustom gives each story complete, in the order the stories are passed to the export
+	OrderCustom
+)
+
+func (o ExportOrder) String() string {
+	switch o {
+	case OrderAsPublished:
+		return "As Published"
+	case OrderByStory:
+		return "Story by Story"
+	case OrderCustom:
+		return "Custom"
+	}
+	return ""
+}
+
+// ExportOptions controls what goes into an export, and how it is built
+type ExportOptions struct {
+	ArtistsEdition  bool
+	IncludeReprints bool
+	Order           ExportOrder
+	// ProgBookmarks adds a bookmark for each part's source prog
+	ProgBookmarks bool
+	// GeneratedPages adds title, contents and credits pages to a PDF export
+	GeneratedPages bool
+	// PageFilters decide which pages are left out. When nil, the scan package's defaults are used.
+	PageFilters []scanApi.PageFilterRule
+	// PageOverrides are the pages kept or left out by hand in the export preview
+	PageOverrides []scanApi.PageOverride
+	// Volumes splits a large export into several files
+	Volumes scanApi.VolumeOptions
+	// Profile downscales and recompresses page images for the device the export is read on
+	Profile scanApi.ExportProfile
+	// Spreads joins double-page spreads onto a single wide page
+	Spreads scanApi.SpreadOptions
+	// Print imposes a PDF export as a booklet or 2-up sheets for printing
+	Print scanApi.PrintOptions
+	// Scroll stitches each episode into tall strips for reading on a phone
+	Scroll scanApi.ScrollOptions
+	// Panels records the panels on each page of a CBZ export for guided view
+	Panels bool
+	// Progress, when set, is called as each episode and page is exported
+	Progress func(scanApi.ProgressEvent)
+}
+
 type Downloadable struct {
 	Comic      download.DigitalComic
 	Downloaded bool
@@ -10,6 +10,7 @@ import (
 type ScanOperation struct {
 	IsRunning binding.Bool
 	Stories   binding.UntypedList
+	Progress  binding.String
 	Error     binding.String
 	cancel    context.CancelFunc
 }
@@ -73,6 +74,7 @@ func NewScanOperation() *ScanOperation {
 	return &ScanOperation{
 		IsRunning: binding.NewBool(),
 		Stories:   binding.NewUntypedList(),
+		Progress:  binding.NewString(),
 		Error:     binding.NewString(),
 	}
 }
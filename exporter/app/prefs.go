@@ -1,8 +1,12 @@
 package app
 
 import (
+	"cmp"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/data/binding"
+	"github.com/chooban/progger/exporter/services"
+	"github.com/chooban/progger/scan"
 	"github.com/zalando/go-keyring"
 )
 
@@ -48,6 +52,10 @@ type Prefs struct {
 	ProgSourceDir     binding.String
 	MegazineSourceDir binding.String
 	BoundExportDir    binding.String
+	// FilenameTemplate names exports, and may start subdirectories of the export directory
+	FilenameTemplate binding.String
+	// Duplicates names the rule for picking which file to keep when an issue is found more than once
+	Duplicates binding.String
 }
 
 func (p *Prefs) RebellionDetails() (string, string) {
@@ -74,11 +82,35 @@ func (p *Prefs) ExportDirectory() string {
 	return exportDir
 }
 
+// ExportFilenameTemplate is the template exports are named with, or the default if none has been set
+func (p *Prefs) ExportFilenameTemplate() string {
+	template, _ := p.FilenameTemplate.Get()
+
+	return cmp.Or(template, services.DefaultFilenameTemplate)
+}
+
+// DuplicatePreference is the rule for picking which file to keep when a scan finds an issue more than once.
+// The newest file is kept until a rule has been chosen, or if the one chosen is no longer known.
+func (p *Prefs) DuplicatePreference() scan.DuplicatePreference {
+	name, _ := p.Duplicates.Get()
+	if name == "" {
+		return scan.PreferNewest
+	}
+	preference, err := scan.ParseDuplicatePreference(name)
+	if err != nil {
+		println(err.Error())
+	}
+
+	return preference
+}
+
 func NewPrefs(a fyne.App) *Prefs {
 	return &Prefs{
 		app:               a,
 		ProgSourceDir:     boundStringValue(a, "ProgSourceDir"),
 		MegazineSourceDir: boundStringValue(a, "MegazineSourceDir"),
 		BoundExportDir:    boundStringValue(a, "ExportDir"),
+		FilenameTemplate:  boundStringValue(a, "FilenameTemplate"),
+		Duplicates:        boundStringValue(a, "DuplicatePreference"),
 	}
 }
@@ -14,6 +14,7 @@ type State struct {
 	services       *AppServices
 	IsDownloading  binding.Bool
 	IsScanning     binding.Bool
+	ScanProgress   binding.String
 	Stories        binding.UntypedList
 	AvailableProgs binding.UntypedList
 	ToDownload     binding.UntypedList
@@ -27,6 +28,7 @@ func NewAppState(s *AppServices) *State {
 		services:       s,
 		IsDownloading:  binding.NewBool(),
 		IsScanning:     binding.NewBool(),
+		ScanProgress:   binding.NewString(),
 		Stories:        binding.NewUntypedList(),
 		AvailableProgs: availableProgs,
 		ToDownload:     binding.NewUntypedList(),
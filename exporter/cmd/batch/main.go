@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/chooban/progger/exporter/services"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zerologr"
+	"github.com/rs/zerolog"
+)
+
+// batch runs the exports in a job file using the stories found by the last scan in the GUI
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] job.json\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	storageDir := flag.String("storage", "", "Directory holding Progger's scanned stories. Defaults to the GUI's.")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	job, err := services.LoadJob(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *storageDir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "could not get user config dir:", err)
+			os.Exit(1)
+		}
+		*storageDir = filepath.Join(configDir, "progger")
+	}
+	storage := services.NewStorage(*storageDir)
+	stories := storage.ReadStories()
+	if len(stories) == 0 {
+		fmt.Fprintln(os.Stderr, "no stories found. Scan your progs in Progger first.")
+		os.Exit(1)
+	}
+
+	// The GUI's logger setup lives in the app package, which would bring in Fyne
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	zl := zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339})
+	logger := zerologr.New(&zl)
+	// Interrupting stops the export in progress, which removes its partly written file
+	ctx, stop := signal.NotifyContext(logr.NewContext(context.Background(), logger), os.Interrupt)
+	defer stop()
+
+	failed := 0
+	for _, r := range services.NewExporter().RunJob(ctx, job, stories) {
+		if r.Err != nil {
+			logger.Error(r.Err, "Export failed", "name", r.Name, "file", r.File)
+			failed++
+			continue
+		}
+		for _, v := range r.Report.Validation {
+			for _, p := range v.Problems {
+				logger.Info("Export failed validation", "name", r.Name, "file", v.File, "check", p.Check, "problem", p.Message)
+			}
+		}
+		if err := storage.SaveExport(r.Record); err != nil {
+			logger.Error(err, "Could not add export to the export history", "name", r.Name)
+		}
+		saved, percent := r.Report.Savings.Saved()
+		logger.Info("Exported", "name", r.Name, "files", r.Report.Files, "dropped_pages", len(r.Report.Dropped),
+			"joined_spreads", len(r.Report.Spreads), "panels", r.Report.Panels, "bytes_saved", saved, "percent_saved", int(percent))
+	}
+	if failed > 0 {
+		stop()
+		os.Exit(1)
+	}
+}
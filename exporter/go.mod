@@ -12,6 +12,7 @@ require (
 	github.com/go-logr/zerologr v1.2.3
 	github.com/rs/zerolog v1.32.0
 	github.com/sdomino/scribble v0.0.0-20230717151034-b95d4df19aa8
+	github.com/stretchr/testify v1.8.4
 	github.com/zalando/go-keyring v0.2.4
 	golang.org/x/exp v0.0.0-20240613232115-7f521ea00fb8
 )
@@ -58,7 +59,6 @@ require (
 	github.com/rymdport/portal v0.3.0 // indirect
 	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c // indirect
 	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
 	github.com/texttheater/golang-levenshtein/levenshtein v0.0.0-20200805054039-cae8b0eaed6c // indirect
 	github.com/yuin/goldmark v1.7.1 // indirect
 	golang.org/x/image v0.18.0 // indirect
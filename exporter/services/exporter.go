@@ -8,45 +8,133 @@ import (
 	exporterApi "github.com/chooban/progger/exporter/api"
 	"github.com/chooban/progger/scan"
 	"github.com/chooban/progger/scan/api"
+	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 )
 
 type Exporter struct {
 }
 
-func (e *Exporter) Export(ctx context.Context, stories []*exporterApi.Story, artistsEdition bool, exportDir, filename string) error {
-	toExport := make([]api.ExportPage, 0)
+func (e *Exporter) Export(ctx context.Context, stories []*exporterApi.Story, options exporterApi.ExportOptions, exportDir, filename string) (api.BuildReport, error) {
+	if options.Print.Layout != api.PrintPages && !strings.EqualFold(filepath.Ext(filename), ".pdf") {
+		return api.BuildReport{}, errors.New("print layouts can only be exported as a PDF")
+	}
+	if options.Print.Layout != api.PrintPages && options.Scroll.Enabled {
+		return api.BuildReport{}, errors.New("a vertical scroll can't be laid out for printing")
+	}
+	if options.Panels && !strings.EqualFold(filepath.Ext(filename), ".cbz") {
+		return api.BuildReport{}, errors.New("guided view panels can only be exported in a CBZ")
+	}
+	if options.Panels && options.Scroll.Enabled {
+		return api.BuildReport{}, errors.New("guided view panels can't be exported in a vertical scroll")
+	}
+	toExport, err := exportPages(stories, options)
+	if err != nil {
+		return api.BuildReport{}, err
+	}
+
+	// The filename may put the export in a subdirectory, which is made if it doesn't exist yet
+	outputPath := filepath.Join(exportDir, filename)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return api.BuildReport{}, err
+	}
+
+	// Do the export
+	return scan.BuildVolumes(ctx, toExport, buildOptions(options), options.Volumes, outputPath)
+}
+
+// Preview lists the pages an export of the stories would contain, and those it would leave out, with a
+// thumbnail of each, so that pages can be kept or left out by hand before building it
+func (e *Exporter) Preview(ctx context.Context, stories []*exporterApi.Story, options exporterApi.ExportOptions) ([]api.PreviewPage, error) {
+	toExport, err := exportPages(stories, options)
+	if err != nil {
+		return nil, err
+	}
+	return scan.Preview(ctx, toExport, buildOptions(options))
+}
+
+// exportPages lists the episodes of the stories to export, in the export's order
+func exportPages(stories []*exporterApi.Story, options exporterApi.ExportOptions) ([]api.ExportPage, error) {
+	byStory := make([][]api.ExportPage, 0, len(stories))
 	for _, story := range stories {
-		if story.ToExport {
-			for _, e := range story.Episodes {
-				toExport = append(toExport, api.ExportPage{
-					Filename:    e.Filename,
-					PageFrom:    e.FirstPage,
-					PageTo:      e.LastPage,
-					IssueNumber: e.IssueNumber,
-					Title:       fmt.Sprintf("%s - Part %d", e.Title, e.Part),
-				})
+		if !story.ToExport {
+			continue
+		}
+		pages := make([]api.ExportPage, 0, len(story.Episodes))
+		for _, e := range story.Episodes {
+			if e.Reprint && !options.IncludeReprints {
+				continue
 			}
+			pages = append(pages, api.ExportPage{
+				Filename:    e.Filename,
+				Publication: e.Publication,
+				PageFrom:    e.FirstPage,
+				PageTo:      e.LastPage,
+				IssueNumber: e.IssueNumber,
+				Title:       fmt.Sprintf("%s - Part %d", e.Title, e.Part),
+				Series:      story.Series,
+				Story:       story.Title,
+				Part:        e.Part,
+				Credits:     e.Credits,
+			})
+		}
+		if len(pages) > 0 {
+			byStory = append(byStory, pages)
 		}
 	}
-	if len(toExport) == 0 {
-		return errors.New("no stories to export")
+	if len(byStory) == 0 {
+		return nil, errors.New("no stories to export")
+	}
+	return orderPages(byStory, options.Order), nil
+}
+
+// buildOptions are the options the scan package builds the export with
+func buildOptions(options exporterApi.ExportOptions) api.BuildOptions {
+	return api.BuildOptions{
+		ArtistsEdition: options.ArtistsEdition,
+		ProgBookmarks:  options.ProgBookmarks,
+		TitlePage:      options.GeneratedPages,
+		ContentsPage:   options.GeneratedPages,
+		CreditsPage:    options.GeneratedPages,
+		PageFilters:    options.PageFilters,
+		PageOverrides:  options.PageOverrides,
+		Profile:        options.Profile,
+		Spreads:        options.Spreads,
+		Print:          options.Print,
+		Scroll:         options.Scroll,
+		Panels:         options.Panels,
+		Progress:       options.Progress,
+	}
+}
+
+// orderPages puts the episodes of each story into the export's order. Bookmarks follow the page order, so
+// they come out in the same order.
+func orderPages(byStory [][]api.ExportPage, order exporterApi.ExportOrder) []api.ExportPage {
+	for _, pages := range byStory {
+		slices.SortStableFunc(pages, comparePublished)
 	}
 
-	// Sort by issue number. We sometimes have issues being wrongly grouped, but surely we never want anything
-	// other than issue order?
-	slices.SortFunc(toExport, func(i, j api.ExportPage) int {
-		return cmp.Compare(i.IssueNumber, j.IssueNumber)
-	})
+	if order == exporterApi.OrderByStory {
+		slices.SortStableFunc(byStory, func(a, b []api.ExportPage) int {
+			return comparePublished(a[0], b[0])
+		})
+	}
 
-	// Do the export
-	err := scan.Build(ctx, toExport, artistsEdition, filepath.Join(exportDir, filename))
-	if err != nil {
-		return err
+	toExport := slices.Concat(byStory...)
+	if order == exporterApi.OrderAsPublished {
+		slices.SortStableFunc(toExport, comparePublished)
 	}
+	return toExport
+}
 
-	return nil
+// comparePublished orders episodes by issue number, then by where they start in the issue
+func comparePublished(a, b api.ExportPage) int {
+	return cmp.Or(
+		cmp.Compare(a.IssueNumber, b.IssueNumber),
+		cmp.Compare(a.PageFrom, b.PageFrom),
+	)
 }
 
 func NewExporter() *Exporter {
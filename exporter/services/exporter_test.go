@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+
+	exporterApi "github.com/chooban/progger/exporter/api"
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportPagesPublication(t *testing.T) {
+	t.Parallel()
+	issues := []api.Issue{
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2301,
+			Filename:    "2000AD 2301 (1977).pdf",
+			Episodes:    []*api.Episode{{Series: "Judge Dredd", Title: "Get Sin", Part: 1, FirstPage: 3, LastPage: 8}},
+		},
+		{
+			Publication: "Judge Dredd Megazine",
+			IssueNumber: 460,
+			Filename:    "Judge Dredd Megazine 460.pdf",
+			Episodes:    []*api.Episode{{Series: "Judge Dredd", Title: "Get Sin", Part: 2, FirstPage: 5, LastPage: 14}},
+		},
+	}
+	stories := toStories(issues)
+	for _, s := range stories {
+		s.ToExport = true
+	}
+
+	pages, err := exportPages(stories, exporterApi.ExportOptions{})
+
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, "Judge Dredd Megazine", pages[0].Publication)
+	assert.Equal(t, 460, pages[0].IssueNumber)
+	assert.Equal(t, "2000 AD", pages[1].Publication)
+	assert.Equal(t, 2301, pages[1].IssueNumber)
+}
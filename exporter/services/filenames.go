@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	exporterApi "github.com/chooban/progger/exporter/api"
+)
+
+// DefaultFilenameTemplate names an export after its stories, as the export dialog always has
+const DefaultFilenameTemplate = "{series} - {story} - {edition}.{format}"
+
+// FilenamePlaceholders are the placeholders a filename template may use
+var FilenamePlaceholders = []string{"{name}", "{series}", "{story}", "{first}", "{last}", "{publication}", "{format}", "{edition}"}
+
+const artistsEdition = "Artists Edition"
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+	emptyBrackets      = regexp.MustCompile(`\(\s*-?\s*\)|\[\s*-?\s*\]`)
+	repeatedSeparators = regexp.MustCompile(`(\s*-\s*){2,}`)
+	exportExtensions   = []string{".pdf", ".cbz", ".epub"}
+)
+
+// FilenameValues are what the placeholders in a filename template are filled in with
+type FilenameValues struct {
+	// Name is the name of a batch export. It is empty for exports from the GUI.
+	Name           string
+	Series         []string
+	Stories        []string
+	Publications   []string
+	First          int
+	Last           int
+	Format         string
+	ArtistsEdition bool
+}
+
+// NewFilenameValues collects the values for the stories being exported. The format is the file extension,
+// without its dot.
+func NewFilenameValues(stories []*exporterApi.Story, format string, artistsEdition bool) FilenameValues {
+	values := FilenameValues{
+		Series:         make([]string, 0, 1),
+		Stories:        make([]string, 0, len(stories)),
+		Publications:   make([]string, 0, 1),
+		Format:         strings.ToLower(strings.TrimPrefix(format, ".")),
+		ArtistsEdition: artistsEdition,
+	}
+	issues := make([]int, 0)
+	for _, s := range stories {
+		if !slices.Contains(values.Series, s.Series) {
+			values.Series = append(values.Series, s.Series)
+		}
+		if !slices.Contains(values.Stories, s.Title) {
+			values.Stories = append(values.Stories, s.Title)
+		}
+		for _, e := range s.Episodes {
+			if e.Publication != "" && !slices.Contains(values.Publications, e.Publication) {
+				values.Publications = append(values.Publications, e.Publication)
+			}
+		}
+		issues = append(issues, s.Issues...)
+	}
+	if len(issues) > 0 {
+		values.First = slices.Min(issues)
+		values.Last = slices.Max(issues)
+	}
+	return values
+}
+
+// ExpandFilename fills in the placeholders of a filename template, giving a path relative to the export
+// directory. A "/" in the template starts a subdirectory. Placeholders with nothing to fill them leave no
+// empty brackets or doubled separators behind, and the extension always matches the format, whether or
+// not the template gives one. An artist's edition is always marked, so if the template has no {edition}
+// it is added to the end of the name.
+func ExpandFilename(template string, values FilenameValues) (string, error) {
+	for _, p := range placeholderPattern.FindAllString(template, -1) {
+		if !slices.Contains(FilenamePlaceholders, p) {
+			return "", fmt.Errorf("unknown placeholder %s in filename template", p)
+		}
+	}
+	if strings.HasPrefix(template, "/") || filepath.IsAbs(template) {
+		return "", fmt.Errorf("filename template %q must be relative to the export directory", template)
+	}
+
+	original := template
+	edition := ""
+	if values.ArtistsEdition {
+		edition = artistsEdition
+	}
+	first, last := "", ""
+	if values.First > 0 {
+		first, last = strconv.Itoa(values.First), strconv.Itoa(values.Last)
+	}
+
+	template = trimExportExtension(template)
+	template = strings.TrimSuffix(template, ".{format}")
+	if values.ArtistsEdition && !strings.Contains(template, "{edition}") {
+		template += " - {edition}"
+	}
+
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		segment = strings.NewReplacer(
+			"{name}", filenameValue(values.Name),
+			"{series}", filenameValue(strings.Join(values.Series, ", ")),
+			"{story}", filenameValue(strings.Join(values.Stories, ", ")),
+			"{first}", first,
+			"{last}", last,
+			"{publication}", filenameValue(strings.Join(values.Publications, ", ")),
+			"{format}", values.Format,
+			"{edition}", edition,
+		).Replace(segment)
+		segment = tidyFilename(segment)
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("filename template %q gives an empty or relative name for the stories exported", original)
+		}
+		segments[i] = segment
+	}
+	return filepath.FromSlash(path.Join(segments...)) + "." + values.Format, nil
+}
+
+// trimExportExtension removes the extension of an export format from the end of a template
+func trimExportExtension(template string) string {
+	ext := strings.ToLower(path.Ext(template))
+	if slices.Contains(exportExtensions, ext) {
+		return strings.TrimSuffix(template, template[len(template)-len(ext):])
+	}
+	return template
+}
+
+// filenameValue makes a value safe to put in a file name, so that titles with slashes or colons in don't
+// start directories or trip up other filesystems
+func filenameValue(v string) string {
+	return strings.NewReplacer("/", "-", "\\", "-", ":", "", "*", "", "?", "", "\"", "", "<", "", ">", "", "|", "").Replace(v)
+}
+
+// tidyFilename clears up after placeholders that were left empty
+func tidyFilename(name string) string {
+	name = emptyBrackets.ReplaceAllString(name, "")
+	name = repeatedSeparators.ReplaceAllString(name, " - ")
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.Trim(name, " -_")
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandFilename(t *testing.T) {
+	t.Parallel()
+	values := FilenameValues{
+		Series:       []string{"Judge Dredd"},
+		Stories:      []string{"Get Sin"},
+		Publications: []string{"2000 AD"},
+		First:        2301,
+		Last:         2304,
+		Format:       "pdf",
+	}
+	artistsEdition := values
+	artistsEdition.ArtistsEdition = true
+	noIssues := values
+	noIssues.First, noIssues.Last = 0, 0
+	slashedTitle := values
+	slashedTitle.Stories = []string{"Dredd/Anderson: Mind Games"}
+
+	testCases := []struct {
+		name          string
+		template      string
+		values        FilenameValues
+		expected      string
+		expectedError bool
+	}{
+		{
+			name:     "Default template",
+			template: DefaultFilenameTemplate,
+			values:   values,
+			expected: "Judge Dredd - Get Sin.pdf",
+		},
+		{
+			name:     "Default template for an artist's edition",
+			template: DefaultFilenameTemplate,
+			values:   artistsEdition,
+			expected: "Judge Dredd - Get Sin - Artists Edition.pdf",
+		},
+		{
+			name:     "Edition added when the template has none",
+			template: "{story}",
+			values:   artistsEdition,
+			expected: "Get Sin - Artists Edition.pdf",
+		},
+		{
+			name:     "Export extension trimmed",
+			template: "{story}.epub",
+			values:   values,
+			expected: "Get Sin.pdf",
+		},
+		{
+			name:     "Other extensions kept",
+			template: "{story}.v2",
+			values:   values,
+			expected: "Get Sin.v2.pdf",
+		},
+		{
+			name:     "Issue range",
+			template: "{story} ({first}-{last})",
+			values:   values,
+			expected: "Get Sin (2301-2304).pdf",
+		},
+		{
+			name:     "Empty brackets removed",
+			template: "{story} ({first}-{last}) [{edition}]",
+			values:   noIssues,
+			expected: "Get Sin.pdf",
+		},
+		{
+			name:     "Doubled separators collapsed",
+			template: "{series} - {name} - {story}",
+			values:   values,
+			expected: "Judge Dredd - Get Sin.pdf",
+		},
+		{
+			name:     "Subdirectories",
+			template: "{publication}/{series}/{story}",
+			values:   values,
+			expected: filepath.Join("2000 AD", "Judge Dredd", "Get Sin.pdf"),
+		},
+		{
+			name:     "Slashes in values don't start directories",
+			template: "{series}/{story}",
+			values:   slashedTitle,
+			expected: filepath.Join("Judge Dredd", "Dredd-Anderson Mind Games.pdf"),
+		},
+		{
+			name:          "Absolute path",
+			template:      "/tmp/{story}",
+			values:        values,
+			expectedError: true,
+		},
+		{
+			name:          "Parent directory",
+			template:      "../{story}",
+			values:        values,
+			expectedError: true,
+		},
+		{
+			name:          "Empty directory",
+			template:      "{name}/{story}",
+			values:        values,
+			expectedError: true,
+		},
+		{
+			name:          "Unknown placeholder",
+			template:      "{title}",
+			values:        values,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			filename, err := ExpandFilename(tc.template, tc.values)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, filename)
+		})
+	}
+}
+
+func TestTidyFilename(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Nothing to tidy", input: "Judge Dredd - Get Sin", expected: "Judge Dredd - Get Sin"},
+		{name: "Empty round brackets", input: "Get Sin ()", expected: "Get Sin"},
+		{name: "Bracketed separator", input: "Get Sin ( - )", expected: "Get Sin"},
+		{name: "Empty square brackets", input: "Get Sin [ ]", expected: "Get Sin"},
+		{name: "Doubled separators", input: "Judge Dredd -  - Get Sin", expected: "Judge Dredd - Get Sin"},
+		{name: "Trailing separator", input: "Get Sin - ", expected: "Get Sin"},
+		{name: "Leading separator", input: " - Get Sin", expected: "Get Sin"},
+		{name: "Repeated spaces", input: "Get   Sin", expected: "Get Sin"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, tidyFilename(tc.input))
+		})
+	}
+}
+
+func TestExpandFilename_ScannedStories(t *testing.T) {
+	t.Parallel()
+	stories := toStories([]api.Issue{
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2301,
+			Filename:    "2000AD 2301 (1977).pdf",
+			Episodes:    []*api.Episode{{Series: "Judge Dredd", Title: "Get Sin", Part: 1}},
+		},
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2302,
+			Filename:    "2000AD 2302 (1977).pdf",
+			Episodes:    []*api.Episode{{Series: "Judge Dredd", Title: "Get Sin", Part: 2}},
+		},
+	})
+
+	filename, err := ExpandFilename("{publication}/{series} - {story} ({first}-{last})", NewFilenameValues(stories, "cbz", false))
+
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("2000 AD", "Judge Dredd - Get Sin (2301-2302).cbz"), filename)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	exporterApi "github.com/chooban/progger/exporter/api"
+	"github.com/chooban/progger/scan/api"
+)
+
+// An ExportRecord is an export that has been made, kept so that it can be made again once more parts of its
+// stories have been scanned
+type ExportRecord struct {
+	ID   string
+	Time time.Time
+	// Export describes the export in the same form as an export in a job. Its filename is the template the
+	// export was named from, or the name typed in for it.
+	Export        JobExport
+	Volumes       api.VolumeOptions
+	PageOverrides []api.PageOverride
+	Files         []string
+	Pages         int
+	// LastIssues is the latest issue each story in the export took an episode from, by storyKey. Stories
+	// are kept apart because the progs of one publication can't be compared with those of another.
+	LastIssues map[string]int
+}
+
+// ErrNoNewParts is returned when re-exporting finds no episodes newer than the export's
+var ErrNoNewParts = errors.New("no new parts have been scanned since the export was made")
+
+var jobOrderNames = map[exporterApi.ExportOrder]string{
+	exporterApi.OrderAsPublished: "published",
+	exporterApi.OrderByStory:     "story",
+	exporterApi.OrderCustom:      "custom",
+}
+
+// NewExportRecord records an export made from the GUI, so that it can be repeated. The template is the
+// filename template the export was named from, and is empty if its name was typed in by hand, in which case
+// it keeps that name.
+func NewExportRecord(stories []*exporterApi.Story, options exporterApi.ExportOptions, exportDir, filename, template string, report api.BuildReport) ExportRecord {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	je := JobExport{
+		Name:                strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)),
+		Stories:             make([]JobStory, 0, len(stories)),
+		Format:              format,
+		ArtistsEdition:      options.ArtistsEdition,
+		IncludeReprints:     &options.IncludeReprints,
+		ProgBookmarks:       options.ProgBookmarks,
+		GeneratedPages:      options.GeneratedPages,
+		JoinSpreads:         options.Spreads.Join,
+		KeepSpreadOriginals: options.Spreads.KeepOriginals,
+		PaperSize:           options.Print.PaperSize,
+		CropMarks:           options.Print.CropMarks,
+		Scroll:              options.Scroll.Enabled,
+		ScrollWidth:         options.Scroll.Width,
+		Panels:              options.Panels,
+		Order:               jobOrderNames[options.Order],
+		Profile:             options.Profile.Name,
+		Filename:            cmp.Or(template, filename),
+		Destination:         exportDir,
+	}
+	if options.Print.Layout != api.PrintPages {
+		je.Print = strings.ToLower(options.Print.Layout.String())
+	}
+	for _, s := range stories {
+		if !s.ToExport || len(s.Issues) == 0 {
+			continue
+		}
+		je.Stories = append(je.Stories, JobStory{
+			Series: s.Series,
+			Title:  s.Title,
+			From:   slices.Min(s.Issues),
+			To:     slices.Max(s.Issues),
+		})
+	}
+	return newExportRecord(je, options.Volumes, options.PageOverrides, stories, report)
+}
+
+func newExportRecord(je JobExport, volumes api.VolumeOptions, overrides []api.PageOverride, stories []*exporterApi.Story, report api.BuildReport) ExportRecord {
+	now := time.Now()
+	record := ExportRecord{
+		ID:            fmt.Sprintf("export_%d", now.UnixNano()),
+		Time:          now,
+		Export:        je,
+		Volumes:       volumes,
+		PageOverrides: overrides,
+		Files:         report.Files,
+		Pages:         report.Pages,
+		LastIssues:    make(map[string]int, len(stories)),
+	}
+	for _, s := range stories {
+		if len(s.Issues) > 0 {
+			record.LastIssues[storyKey(s)] = max(record.LastIssues[storyKey(s)], slices.Max(s.Issues))
+		}
+	}
+	return record
+}
+
+// storyKey tells stories apart in the same way a scan does, so that a reprint isn't taken for the original
+func storyKey(s *exporterApi.Story) string {
+	key := s.Series + " - " + s.Title
+	if s.Reprint {
+		key += " - reprint"
+	}
+	return key
+}
+
+// hasNewParts reports whether a story has any episodes from after those the export was made with. A story
+// the export didn't have is all new.
+func (r ExportRecord) hasNewParts(s *exporterApi.Story) bool {
+	last, ok := r.LastIssues[storyKey(s)]
+	if !ok {
+		return len(s.Issues) > 0
+	}
+	return slices.ContainsFunc(s.Issues, func(issue int) bool { return issue > last })
+}
+
+// Display names the export and its stories for the export history
+func (r ExportRecord) Display() string {
+	stories := make([]string, 0, len(r.Export.Stories))
+	for _, s := range r.Export.Stories {
+		stories = append(stories, strings.Join([]string{s.Series, s.Title}, " - "))
+	}
+	return fmt.Sprintf("%s (%s)", r.Export.Name, strings.Join(stories, ", "))
+}
+
+// Reexport makes an export again with any parts of its stories scanned since, which follow on from the
+// progs it was made from. The record of the new export is returned, to be added to the history. If there
+// are no new parts, ErrNoNewParts is returned and nothing is exported. Progress, when set, is called as the
+// export is built.
+func (e *Exporter) Reexport(ctx context.Context, record ExportRecord, stories []exporterApi.Story, progress func(api.ProgressEvent)) (ExportRecord, api.BuildReport, error) {
+	je := record.Export
+	je.Stories = slices.Clone(je.Stories)
+	for i := range je.Stories {
+		je.Stories[i].To = 0
+	}
+
+	selected := je.Select(stories)
+	if len(selected) == 0 {
+		return ExportRecord{}, api.BuildReport{}, errors.New("none of the export's stories have been found")
+	}
+	if !slices.ContainsFunc(selected, record.hasNewParts) {
+		return ExportRecord{}, api.BuildReport{}, ErrNoNewParts
+	}
+
+	dir, filename, err := je.OutputFile(&Job{}, selected)
+	if err != nil {
+		return ExportRecord{}, api.BuildReport{}, err
+	}
+	options := je.exportOptions()
+	options.Volumes = record.Volumes
+	options.PageOverrides = record.PageOverrides
+	options.Progress = progress
+	report, err := e.Export(ctx, selected, options, dir, filename)
+	if err != nil {
+		return ExportRecord{}, report, err
+	}
+	return newExportRecord(je, record.Volumes, record.PageOverrides, selected, report), report, nil
+}
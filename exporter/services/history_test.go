@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+
+	exporterApi "github.com/chooban/progger/exporter/api"
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportRecordHasNewParts(t *testing.T) {
+	t.Parallel()
+	dredd := &exporterApi.Story{Series: "Judge Dredd", Title: "Get Sin", Issues: []int{2301, 2302}}
+	anderson := &exporterApi.Story{Series: "Anderson", Title: "Psi Division", Issues: []int{460, 461}}
+	record := newExportRecord(JobExport{}, api.VolumeOptions{}, nil, []*exporterApi.Story{dredd, anderson}, api.BuildReport{})
+
+	testCases := []struct {
+		name     string
+		story    *exporterApi.Story
+		expected bool
+	}{
+		{
+			name:     "No new parts",
+			story:    &exporterApi.Story{Series: "Judge Dredd", Title: "Get Sin", Issues: []int{2301, 2302}},
+			expected: false,
+		},
+		{
+			name:     "New part",
+			story:    &exporterApi.Story{Series: "Judge Dredd", Title: "Get Sin", Issues: []int{2301, 2302, 2303}},
+			expected: true,
+		},
+		{
+			// Megazine numbers are far lower than the progs exported alongside them
+			name:     "New part of a story from another publication",
+			story:    &exporterApi.Story{Series: "Anderson", Title: "Psi Division", Issues: []int{460, 461, 462}},
+			expected: true,
+		},
+		{
+			name:     "Story not in the export",
+			story:    &exporterApi.Story{Series: "Judge Dredd", Title: "The Harvest", Issues: []int{2310}},
+			expected: true,
+		},
+		{
+			name:     "Reprint of a story in the export",
+			story:    &exporterApi.Story{Series: "Judge Dredd", Title: "Get Sin", Issues: []int{2301}, Reprint: true},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, record.hasNewParts(tc.story))
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	exporterApi "github.com/chooban/progger/exporter/api"
+	"github.com/chooban/progger/scan/api"
+)
+
+// A Job is a list of exports read from a job file, so that the same exports can be repeated without the GUI.
+//
+//	{
+//	  "destination": "/home/me/Comics",
+//	  "exports": [
+//	    {
+//	      "name": "Brink Book Four",
+//	      "stories": [{ "series": "Brink", "title": "Hate Box", "from": 2301, "to": 2310 }],
+//	      "format": "epub",
+//	      "filename": "{series} - {story} ({first}-{last})"
+//	    }
+//	  ]
+//	}
+type Job struct {
+	// Destination is the directory exports are written to, unless an export names its own
+	Destination string `json:"destination"`
+	// Filename is the filename template for exports that don't give their own
+	Filename string      `json:"filename"`
+	Exports  []JobExport `json:"exports"`
+}
+
+// JobExport is a single export in a job, built into one file, or one per volume
+type JobExport struct {
+	Name    string     `json:"name"`
+	Stories []JobStory `json:"stories"`
+	// Format is one of pdf, cbz or epub. It defaults to pdf.
+	Format         string `json:"format"`
+	ArtistsEdition bool   `json:"artistsEdition"`
+	// IncludeReprints exports the episodes of a story that are reprints, as the GUI does. It defaults to
+	// true, so is only needed to leave reprints out.
+	IncludeReprints *bool `json:"includeReprints"`
+	ProgBookmarks   bool  `json:"progBookmarks"`
+	GeneratedPages  bool  `json:"generatedPages"`
+	// JoinSpreads puts double-page spreads onto a single wide page, and KeepSpreadOriginals follows each
+	// with the two pages it was joined from
+	JoinSpreads         bool `json:"joinSpreads"`
+	KeepSpreadOriginals bool `json:"keepSpreadOriginals"`
+	// Print is "booklet" or "2-up" to impose a PDF for printing, on sheets of PaperSize, such as "A3", which
+	// is the default. CropMarks adds marks to trim each page along.
+	Print     string `json:"print"`
+	PaperSize string `json:"paperSize"`
+	CropMarks bool   `json:"cropMarks"`
+	// Scroll stitches each episode's pages into tall strips for reading on a phone, scaled to ScrollWidth
+	// pixels, which defaults to 1080
+	Scroll      bool `json:"scroll"`
+	ScrollWidth int  `json:"scrollWidth"`
+	// Panels records the panels on each page of a CBZ export, for guided view
+	Panels bool `json:"panels"`
+	// Order is "published" to interleave the stories as they appeared, which is the default, "story" to give
+	// each story complete starting with the earliest, or "custom" to give each story complete in the order
+	// they are listed in the job.
+	Order string `json:"order"`
+	// Profile names the export profile used to resample page images, such as "Tablet". It defaults to
+	// keeping the original images.
+	Profile string `json:"profile"`
+	// Filename is a template for the exported file's name, in the same form as the GUI's, and may start
+	// subdirectories of the destination. It defaults to the job's template, and then to the export's name.
+	Filename    string `json:"filename"`
+	Destination string `json:"destination"`
+	// PageFilters are the rules for leaving pages, such as adverts, out of the export. When left out, the
+	// default rules are used. An empty list keeps every page.
+	PageFilters []JobPageFilter `json:"pageFilters"`
+}
+
+// JobPageFilter is a rule for leaving pages out of an export. A page is left out if its text matches Text,
+// a regular expression, if it is classified as Classification, "advert" or "letters", or if it looks like
+// one of the Images. TrailingOnly limits the rule to the pages at the end of an episode.
+type JobPageFilter struct {
+	Name           string   `json:"name"`
+	Text           string   `json:"text"`
+	Classification string   `json:"classification"`
+	Images         []string `json:"images"`
+	Similarity     int      `json:"similarity"`
+	TrailingOnly   bool     `json:"trailingOnly"`
+}
+
+func (f JobPageFilter) rule() api.PageFilterRule {
+	return api.PageFilterRule{
+		Name:           f.Name,
+		Text:           f.Text,
+		Classification: jobPageClasses[strings.ToLower(f.Classification)],
+		Images:         f.Images,
+		Similarity:     f.Similarity,
+		TrailingOnly:   f.TrailingOnly,
+	}
+}
+
+// JobStory selects stories by series and, optionally, title. The issue range limits which episodes are
+// exported, and is open-ended when From or To are left out.
+type JobStory struct {
+	Series string `json:"series"`
+	Title  string `json:"title"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+}
+
+// JobResult is the outcome of one export in a job
+type JobResult struct {
+	Name   string
+	File   string
+	Report api.BuildReport
+	Err    error
+	// Record is the export's entry for the export history, when it succeeded
+	Record ExportRecord
+}
+
+var jobFormats = []string{"pdf", "cbz", "epub"}
+
+var jobPrintLayouts = map[string]api.PrintLayout{
+	"":        api.PrintPages,
+	"booklet": api.PrintBooklet,
+	"2-up":    api.PrintTwoUp,
+}
+
+var jobPageClasses = map[string]api.PageClass{
+	"":        api.PageUnclassified,
+	"advert":  api.PageAdvert,
+	"letters": api.PageLetters,
+}
+
+var jobOrders = map[string]exporterApi.ExportOrder{
+	"":          exporterApi.OrderAsPublished,
+	"published": exporterApi.OrderAsPublished,
+	"story":     exporterApi.OrderByStory,
+	"custom":    exporterApi.OrderCustom,
+}
+
+// LoadJob reads and checks a job file
+func LoadJob(filename string) (*Job, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	job := &Job{}
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(job); err != nil {
+		return nil, fmt.Errorf("reading job file %s: %w", filename, err)
+	}
+	if err := job.validate(); err != nil {
+		return nil, fmt.Errorf("job file %s: %w", filename, err)
+	}
+	return job, nil
+}
+
+func (j *Job) validate() error {
+	if len(j.Exports) == 0 {
+		return errors.New("no exports")
+	}
+	for i := range j.Exports {
+		e := &j.Exports[i]
+		if e.Name == "" {
+			return fmt.Errorf("export %d has no name", i+1)
+		}
+		if len(e.Stories) == 0 {
+			return fmt.Errorf("export %q has no stories", e.Name)
+		}
+		for _, s := range e.Stories {
+			if s.Series == "" {
+				return fmt.Errorf("export %q has a story with no series", e.Name)
+			}
+			if s.To > 0 && s.From > s.To {
+				return fmt.Errorf("export %q has an issue range of %d to %d", e.Name, s.From, s.To)
+			}
+		}
+		e.Format = strings.ToLower(e.Format)
+		if e.Format == "" {
+			e.Format = "pdf"
+		}
+		if !slices.Contains(jobFormats, e.Format) {
+			return fmt.Errorf("export %q has unknown format %q", e.Name, e.Format)
+		}
+		if _, ok := jobOrders[strings.ToLower(e.Order)]; !ok {
+			return fmt.Errorf("export %q has unknown order %q", e.Name, e.Order)
+		}
+		if _, ok := exportProfile(e.Profile); !ok {
+			return fmt.Errorf("export %q has unknown profile %q", e.Name, e.Profile)
+		}
+		layout, ok := jobPrintLayouts[strings.ToLower(e.Print)]
+		if !ok {
+			return fmt.Errorf("export %q has unknown print layout %q", e.Name, e.Print)
+		}
+		if layout != api.PrintPages && e.Format != "pdf" {
+			return fmt.Errorf("export %q can only be printed as a pdf", e.Name)
+		}
+		if layout != api.PrintPages && e.Scroll {
+			return fmt.Errorf("export %q can't lay out a vertical scroll for printing", e.Name)
+		}
+		if e.Panels && e.Format != "cbz" {
+			return fmt.Errorf("export %q can only have guided view panels as a cbz", e.Name)
+		}
+		if e.Panels && e.Scroll {
+			return fmt.Errorf("export %q can't have guided view panels in a vertical scroll", e.Name)
+		}
+		if e.ScrollWidth < 0 {
+			return fmt.Errorf("export %q has a scroll width of %d", e.Name, e.ScrollWidth)
+		}
+		for k, f := range e.PageFilters {
+			if _, ok := jobPageClasses[strings.ToLower(f.Classification)]; !ok {
+				return fmt.Errorf("export %q page filter %d has unknown classification %q", e.Name, k+1, f.Classification)
+			}
+			if _, err := regexp.Compile(f.Text); err != nil {
+				return fmt.Errorf("export %q page filter %d: %w", e.Name, k+1, err)
+			}
+			if f.Similarity < 0 || f.Similarity > 64 {
+				return fmt.Errorf("export %q page filter %d has a similarity of %d", e.Name, k+1, f.Similarity)
+			}
+		}
+		if e.Destination == "" && j.Destination == "" {
+			return fmt.Errorf("export %q has no destination", e.Name)
+		}
+	}
+	return nil
+}
+
+// Select picks out the stories the export asks for, trimmed to the issue ranges given, in the order they
+// are listed in the job. The stories are copies, marked for export.
+func (e JobExport) Select(stories []exporterApi.Story) []*exporterApi.Story {
+	type match struct {
+		listed int
+		story  *exporterApi.Story
+	}
+	matches := make([]match, 0)
+	for _, s := range stories {
+		idx := slices.IndexFunc(e.Stories, func(js JobStory) bool { return js.matches(s) })
+		if idx < 0 {
+			continue
+		}
+		js := e.Stories[idx]
+
+		story := s
+		story.Episodes = make([]exporterApi.Episode, 0, len(s.Episodes))
+		story.Issues = make([]int, 0, len(s.Issues))
+		for _, ep := range s.Episodes {
+			if js.inRange(ep.IssueNumber) {
+				story.Episodes = append(story.Episodes, ep)
+				if !slices.Contains(story.Issues, ep.IssueNumber) {
+					story.Issues = append(story.Issues, ep.IssueNumber)
+				}
+			}
+		}
+		if len(story.Episodes) == 0 {
+			continue
+		}
+		story.ToExport = true
+		matches = append(matches, match{listed: idx, story: &story})
+	}
+
+	slices.SortStableFunc(matches, func(a, b match) int { return cmp.Compare(a.listed, b.listed) })
+	selected := make([]*exporterApi.Story, 0, len(matches))
+	for _, m := range matches {
+		selected = append(selected, m.story)
+	}
+	return selected
+}
+
+func (js JobStory) matches(s exporterApi.Story) bool {
+	if !strings.EqualFold(js.Series, s.Series) {
+		return false
+	}
+	return js.Title == "" || strings.EqualFold(js.Title, s.Title)
+}
+
+func (js JobStory) inRange(issue int) bool {
+	return (js.From == 0 || issue >= js.From) && (js.To == 0 || issue <= js.To)
+}
+
+// OutputFile is where the export is written, with the placeholders in its filename template filled in
+// from the selected stories. The export's own template is used, then the job's, then the export's name.
+func (e JobExport) OutputFile(job *Job, selected []*exporterApi.Story) (dir, filename string, err error) {
+	dir = e.Destination
+	if dir == "" {
+		dir = job.Destination
+	}
+
+	template := cmp.Or(e.Filename, job.Filename, "{name}")
+	values := NewFilenameValues(selected, e.Format, e.ArtistsEdition)
+	values.Name = e.Name
+	filename, err = ExpandFilename(template, values)
+	return dir, filename, err
+}
+
+// RunJob runs each export in the job in turn. A failed export doesn't stop the others, and its error is
+// returned in its result.
+func (e *Exporter) RunJob(ctx context.Context, job *Job, stories []exporterApi.Story) []JobResult {
+	results := make([]JobResult, 0, len(job.Exports))
+	for _, je := range job.Exports {
+		result := JobResult{Name: je.Name}
+
+		selected := je.Select(stories)
+		if len(selected) == 0 {
+			result.Err = errors.New("no matching stories")
+			results = append(results, result)
+			continue
+		}
+
+		dir, filename, err := je.OutputFile(job, selected)
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		result.File = filepath.Join(dir, filename)
+
+		result.Report, result.Err = e.Export(ctx, selected, je.exportOptions(), dir, filename)
+		if result.Err == nil {
+			recorded := je
+			recorded.Filename = cmp.Or(je.Filename, job.Filename, "{name}")
+			recorded.Destination = dir
+			result.Record = newExportRecord(recorded, api.VolumeOptions{}, nil, selected, result.Report)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// exportOptions are the options the export is built with
+func (je JobExport) exportOptions() exporterApi.ExportOptions {
+	profile, _ := exportProfile(je.Profile)
+	var filters []api.PageFilterRule
+	if je.PageFilters != nil {
+		filters = make([]api.PageFilterRule, 0, len(je.PageFilters))
+		for _, f := range je.PageFilters {
+			filters = append(filters, f.rule())
+		}
+	}
+	return exporterApi.ExportOptions{
+		ArtistsEdition:  je.ArtistsEdition,
+		IncludeReprints: je.IncludeReprints == nil || *je.IncludeReprints,
+		Order:           jobOrders[strings.ToLower(je.Order)],
+		ProgBookmarks:   je.ProgBookmarks,
+		GeneratedPages:  je.GeneratedPages,
+		Profile:         profile,
+		Spreads:         api.SpreadOptions{Join: je.JoinSpreads, KeepOriginals: je.KeepSpreadOriginals},
+		Print: api.PrintOptions{
+			Layout:    jobPrintLayouts[strings.ToLower(je.Print)],
+			PaperSize: je.PaperSize,
+			CropMarks: je.CropMarks,
+		},
+		Scroll:      api.ScrollOptions{Enabled: je.Scroll, Width: je.ScrollWidth},
+		Panels:      je.Panels,
+		PageFilters: filters,
+	}
+}
+
+// exportProfile finds a built-in export profile by name, ignoring case. An empty name is the original
+// profile.
+func exportProfile(name string) (api.ExportProfile, bool) {
+	if name == "" {
+		return api.ExportProfile{}, true
+	}
+	idx := slices.IndexFunc(api.ExportProfiles(), func(p api.ExportProfile) bool {
+		return strings.EqualFold(p.Name, name)
+	})
+	if idx < 0 {
+		return api.ExportProfile{}, false
+	}
+	return api.ExportProfiles()[idx], true
+}
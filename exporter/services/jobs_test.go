@@ -0,0 +1,119 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobPageFilters(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name          string
+		filters       []JobPageFilter
+		expected      []api.PageFilterRule
+		expectedError bool
+	}{
+		{
+			name:     "Defaults when left out",
+			filters:  nil,
+			expected: nil,
+		},
+		{
+			name:     "Every page kept",
+			filters:  []JobPageFilter{},
+			expected: []api.PageFilterRule{},
+		},
+		{
+			name: "Rules",
+			filters: []JobPageFilter{
+				{Name: "Adverts", Classification: "Advert"},
+				{Name: "Trailing adverts", Text: "on sale now", TrailingOnly: true},
+				{Name: "House ads", Images: []string{"ads/subscribe.png"}, Similarity: 12},
+			},
+			expected: []api.PageFilterRule{
+				{Name: "Adverts", Classification: api.PageAdvert},
+				{Name: "Trailing adverts", Text: "on sale now", TrailingOnly: true},
+				{Name: "House ads", Images: []string{"ads/subscribe.png"}, Similarity: 12},
+			},
+		},
+		{
+			name:          "Unknown classification",
+			filters:       []JobPageFilter{{Classification: "cover"}},
+			expectedError: true,
+		},
+		{
+			name:          "Bad regular expression",
+			filters:       []JobPageFilter{{Text: "on sale (now"}},
+			expectedError: true,
+		},
+		{
+			name:          "Similarity out of range",
+			filters:       []JobPageFilter{{Images: []string{"ads/subscribe.png"}, Similarity: 65}},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			job := &Job{
+				Destination: "/comics",
+				Exports: []JobExport{{
+					Name:        "Brink",
+					Stories:     []JobStory{{Series: "Brink"}},
+					PageFilters: tc.filters,
+				}},
+			}
+			err := job.validate()
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, job.Exports[0].exportOptions().PageFilters)
+		})
+	}
+}
+
+func TestJobIncludeReprints(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		export   string
+		expected bool
+	}{
+		{name: "Included by default, as in the GUI", export: `{"name": "Brink"}`, expected: true},
+		{name: "Included", export: `{"name": "Brink", "includeReprints": true}`, expected: true},
+		{name: "Left out", export: `{"name": "Brink", "includeReprints": false}`, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var je JobExport
+			assert.NoError(t, json.Unmarshal([]byte(tc.export), &je))
+			assert.Equal(t, tc.expected, je.exportOptions().IncludeReprints)
+		})
+	}
+}
+
+func TestJobPrintScroll(t *testing.T) {
+	t.Parallel()
+	job := &Job{
+		Destination: "/comics",
+		Exports: []JobExport{{
+			Name:    "Brink",
+			Stories: []JobStory{{Series: "Brink"}},
+			Format:  "pdf",
+			Print:   "booklet",
+			Scroll:  true,
+		}},
+	}
+	assert.Error(t, job.validate())
+
+	job.Exports[0].Scroll = false
+	assert.NoError(t, job.validate())
+}
@@ -2,10 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	exporterApi "github.com/chooban/progger/exporter/api"
 	"github.com/chooban/progger/scan"
 	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
 	"golang.org/x/exp/maps"
 	"sort"
 )
@@ -19,9 +21,18 @@ func toStories(issues []api.Issue) []*exporterApi.Story {
 
 	for _, issue := range issues {
 		for _, episode := range issue.Episodes {
+			key := fmt.Sprintf("%s - %s", episode.Series, episode.Title)
+			if episode.Reprint {
+				key += " - reprint"
+			}
 			// If the series - story combo exists, add to its episodes
-			if story, ok := storyMap[fmt.Sprintf("%s - %s", episode.Series, episode.Title)]; ok {
-				story.Episodes = append(story.Episodes, exporterApi.Episode{Episode: episode, Filename: issue.Filename, IssueNumber: issue.IssueNumber})
+			if story, ok := storyMap[key]; ok {
+				story.Episodes = append(story.Episodes, exporterApi.Episode{
+					Episode:     episode,
+					Filename:    issue.Filename,
+					Publication: issue.Publication,
+					IssueNumber: issue.IssueNumber,
+				})
 				sort.Slice(story.Episodes, func(i, j int) bool {
 					return story.Episodes[i].IssueNumber < story.Episodes[j].IssueNumber
 				})
@@ -39,14 +50,16 @@ func toStories(issues []api.Issue) []*exporterApi.Story {
 					Episodes: []exporterApi.Episode{{
 						Episode:     episode,
 						Filename:    issue.Filename,
+						Publication: issue.Publication,
 						IssueNumber: issue.IssueNumber,
 					}},
 					FirstIssue: issue.IssueNumber,
 					LastIssue:  issue.IssueNumber,
 					Issues:     []int{issue.IssueNumber},
 					ToExport:   false,
+					Reprint:    episode.Reprint,
 				}
-				storyMap[fmt.Sprintf("%s - %s", episode.Series, episode.Title)] = &s
+				storyMap[key] = &s
 			}
 		}
 	}
@@ -65,9 +78,16 @@ func toStories(issues []api.Issue) []*exporterApi.Story {
 	return stories
 }
 
-func (s *Scanner) Scan(ctx context.Context, paths []string, knownTitles, skipTitles []string) ([]*exporterApi.Story, error) {
+// Scan scans each of the paths for issues and groups their episodes into stories. If onIssue is not nil it is
+// called with each issue as soon as it has been read, to allow for progress reporting. When the same issue is
+// found more than once, the file kept is picked according to duplicates.
+func (s *Scanner) Scan(ctx context.Context, paths []string, knownTitles, skipTitles []string, duplicates scan.DuplicatePreference, onIssue func(api.Issue)) ([]*exporterApi.Story, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
 	// Create a scanner with the provided configuration
-	scanner := scan.NewScanner(knownTitles, skipTitles)
+	scanner := scan.NewScanner(knownTitles, skipTitles).
+		WithPageText(true).
+		WithDuplicatePreference(duplicates)
 
 	issues := make([]api.Issue, 0)
 	for _, v := range paths {
@@ -78,16 +98,59 @@ func (s *Scanner) Scan(ctx context.Context, paths []string, knownTitles, skipTit
 		default:
 		}
 
-		foundInPath, err := scanner.Dir(ctx, v, 0)
+		results, err := scanner.Stream(ctx, v, 0)
 		if err != nil {
 			return nil, fmt.Errorf("scanning directory %s: %w", v, err)
 		}
-		issues = append(issues, foundInPath...)
+		for r := range results {
+			if r.Err != nil {
+				logger.Error(r.Err, "Failed to read file", "file", r.Issue.Filename)
+				continue
+			}
+			if onIssue != nil {
+				onIssue(r.Issue)
+			}
+			issues = append(issues, r.Issue)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
 
+	issues = scanner.Finalise(ctx, issues)
+
+	s.storeIndex(ctx, issues)
+
 	return toStories(issues), nil
 }
 
+// Search looks for pages whose text contains the query in the index built by the last scan.
+func (s *Scanner) Search(query string, limit int) ([]api.SearchHit, error) {
+	if s.storage == nil {
+		return nil, errors.New("no storage available to read the search index from")
+	}
+	idx, err := s.storage.ReadSearchIndex()
+	if err != nil {
+		return nil, fmt.Errorf("reading search index: %w", err)
+	}
+	return idx.Search(query, limit), nil
+}
+
+func (s *Scanner) storeIndex(ctx context.Context, issues []api.Issue) {
+	if s.storage == nil {
+		return
+	}
+	idx := scan.NewIndex()
+	for _, issue := range issues {
+		idx.Add(issue)
+	}
+	if err := s.storage.StoreSearchIndex(idx); err != nil {
+		// Searching is a nice to have, so don't fail the scan
+		logger := logr.FromContextOrDiscard(ctx)
+		logger.Error(err, "failed to save search index")
+	}
+}
+
 func NewScanner(storage *Storage) *Scanner {
 	return &Scanner{
 		storage: storage,
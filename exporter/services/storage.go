@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+
 	"github.com/chooban/progger/exporter/api"
+	"github.com/chooban/progger/scan"
 	"github.com/sdomino/scribble"
 )
 
@@ -97,6 +100,60 @@ func (s *Storage) ReadStories() []api.Story {
 	return stories
 }
 
+func (s *Storage) StoreSearchIndex(idx *scan.Index) error {
+	if s.db == nil {
+		return errors.New("db not initialized")
+	}
+	return s.db.Write("search_index", "pages", idx)
+}
+
+func (s *Storage) ReadSearchIndex() (*scan.Index, error) {
+	if s.db == nil {
+		return nil, errors.New("db not initialized")
+	}
+	idx := scan.NewIndex()
+	if err := s.db.Read("search_index", "pages", idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// SaveExport adds an export to the export history
+func (s *Storage) SaveExport(record ExportRecord) error {
+	if s.db == nil {
+		return errors.New("db not initialized")
+	}
+	return s.db.Write("export_history", record.ID, record)
+}
+
+// ReadExports returns the export history, most recent first
+func (s *Storage) ReadExports() []ExportRecord {
+	records, err := s.db.ReadAll("export_history")
+	if err != nil {
+		println(err.Error())
+		return make([]ExportRecord, 0)
+	}
+	exports := make([]ExportRecord, 0, len(records))
+	for _, r := range records {
+		record := ExportRecord{}
+		if err := json.Unmarshal(r, &record); err != nil {
+			fmt.Println("Error", err)
+			continue
+		}
+		exports = append(exports, record)
+	}
+	slices.SortFunc(exports, func(a, b ExportRecord) int { return b.Time.Compare(a.Time) })
+	return exports
+}
+
+// DeleteExport removes an export from the export history. The exported files are left alone.
+func (s *Storage) DeleteExport(id string) error {
+	if s.db == nil {
+		return errors.New("db not initialized")
+	}
+	return s.db.Delete("export_history", id)
+}
+
 func (s *Storage) ReadKnownTitles() []string {
 	records, err := s.db.ReadAll("known_titles")
 	if err != nil {
@@ -1,6 +1,7 @@
 package windows
 
 import (
+	"fmt"
 	"reflect"
 
 	"fyne.io/fyne/v2"
@@ -10,6 +11,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/chooban/progger/exporter/api"
 	"github.com/chooban/progger/exporter/app"
+	scanApi "github.com/chooban/progger/scan/api"
 )
 
 func newDownloadsCanvas(a *app.ProggerApp) fyne.CanvasObject {
@@ -278,6 +280,7 @@ func triggerScanAfterDownload(a *app.ProggerApp) {
 	dirsToScan := []string{a.Services.Prefs.ProgSourceDirectory(), a.Services.Prefs.MegSourceDirectory()}
 	knownTitles := a.Services.Storage.ReadKnownTitles()
 	skipTitles := a.Services.Storage.ReadSkipTitles()
+	duplicates := a.Services.Prefs.DuplicatePreference()
 
 	// Create the operation
 	op := app.NewScanOperation()
@@ -291,7 +294,13 @@ func triggerScanAfterDownload(a *app.ProggerApp) {
 			_ = op.IsRunning.Set(false)
 		}()
 
-		foundStories, err := a.Services.Scanner.Scan(ctx, dirsToScan, knownTitles, skipTitles)
+		scanned := 0
+		onIssue := func(issue scanApi.Issue) {
+			scanned++
+			_ = op.Progress.Set(fmt.Sprintf("Scanned %d issues. Last: %s %d", scanned, issue.Publication, issue.IssueNumber))
+		}
+
+		foundStories, err := a.Services.Scanner.Scan(ctx, dirsToScan, knownTitles, skipTitles, duplicates, onIssue)
 		if err != nil {
 			_ = op.Error.Set(err.Error())
 			return
@@ -322,6 +331,11 @@ func triggerScanAfterDownload(a *app.ProggerApp) {
 		a.State.IsScanning.Set(isRunning)
 	}))
 
+	op.Progress.AddListener(binding.NewDataListener(func() {
+		progress, _ := op.Progress.Get()
+		a.State.ScanProgress.Set(progress)
+	}))
+
 	op.Stories.AddListener(binding.NewDataListener(func() {
 		stories, _ := op.Stories.Get()
 		a.State.Stories.Set(stories)
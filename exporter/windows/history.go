@@ -0,0 +1,145 @@
+package windows
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/chooban/progger/exporter/api"
+	"github.com/chooban/progger/exporter/app"
+	"github.com/chooban/progger/exporter/services"
+	scanApi "github.com/chooban/progger/scan/api"
+)
+
+// newHistoryCanvas lists the exports that have been made, most recent first, each of which can be made
+// again with the parts scanned since. The returned function reloads the list.
+func newHistoryCanvas(a *app.ProggerApp) (fyne.CanvasObject, func()) {
+	records := binding.NewUntypedList()
+	refresh := func() {
+		exports := a.Services.Storage.ReadExports()
+		items := make([]interface{}, 0, len(exports))
+		for _, e := range exports {
+			items = append(items, e)
+		}
+		_ = records.Set(items)
+	}
+
+	list := widget.NewListWithData(
+		records,
+		func() fyne.CanvasObject {
+			title := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			title.Truncation = fyne.TextTruncateEllipsis
+			details := widget.NewLabel("")
+			details.Truncation = fyne.TextTruncateEllipsis
+			buttons := container.NewHBox(widget.NewButton("Re-export with New Parts", nil), widget.NewButton("Delete", nil))
+			return container.NewBorder(nil, nil, nil, buttons, container.NewVBox(title, details))
+		},
+		func(item binding.DataItem, o fyne.CanvasObject) {
+			v, _ := item.(binding.Untyped).Get()
+			record := v.(services.ExportRecord)
+
+			c := o.(*fyne.Container)
+			labels := c.Objects[0].(*fyne.Container)
+			labels.Objects[0].(*widget.Label).SetText(record.Display())
+			labels.Objects[1].(*widget.Label).SetText(historyDetails(record))
+
+			buttons := c.Objects[1].(*fyne.Container)
+			buttons.Objects[0].(*widget.Button).OnTapped = func() {
+				reexport(a, record, refresh)
+			}
+			buttons.Objects[1].(*widget.Button).OnTapped = func() {
+				dialog.ShowConfirm("Delete", "Remove this export from the history? Its files are kept.", func(ok bool) {
+					if !ok {
+						return
+					}
+					if err := a.Services.Storage.DeleteExport(record.ID); err != nil {
+						dialog.ShowError(err, a.RootWindow)
+					}
+					refresh()
+				}, a.RootWindow)
+			}
+		},
+	)
+
+	noExports := widget.NewLabel("Nothing has been exported yet")
+	body := container.NewStack(noExports, list)
+	records.AddListener(binding.NewDataListener(func() {
+		if records.Length() == 0 {
+			showHide(body, noExports)
+		} else {
+			showHide(body, list)
+		}
+	}))
+
+	refresh()
+	return container.NewBorder(nil, widget.NewButton("Refresh", refresh), nil, nil, body), refresh
+}
+
+// historyDetails describes when an export was made, what it made and how far it got
+func historyDetails(record services.ExportRecord) string {
+	details := []string{
+		record.Time.Format("2 Jan 2006 15:04"),
+		strings.ToUpper(record.Export.Format),
+	}
+	if len(record.Files) > 1 {
+		details = append(details, fmt.Sprintf("%d volumes", len(record.Files)))
+	}
+	if record.Pages > 0 {
+		details = append(details, fmt.Sprintf("%d pages", record.Pages))
+	}
+	return strings.Join(details, " · ")
+}
+
+// reexport makes an export again with the stories found by the last scan, adding it to the history
+func reexport(a *app.ProggerApp, record services.ExportRecord, onDone func()) {
+	boundStories, err := a.State.Stories.Get()
+	if err != nil {
+		dialog.ShowError(err, a.RootWindow)
+		return
+	}
+	stories := make([]api.Story, 0, len(boundStories))
+	for _, v := range boundStories {
+		stories = append(stories, *v.(*api.Story))
+	}
+
+	ctx, cancel, _ := app.WithLogger()
+	progress := binding.NewFloat()
+	status := binding.NewString()
+	_ = status.Set("Starting export")
+
+	progressDialog := dialog.NewCustom("Exporting", "Cancel", container.NewVBox(
+		widget.NewLabelWithData(status),
+		widget.NewProgressBarWithData(progress),
+	), a.RootWindow)
+	progressDialog.SetOnClosed(cancel)
+	progressDialog.Resize(fyne.NewSize(400, 100))
+	progressDialog.Show()
+
+	go func() {
+		updated, report, err := a.Services.Exporter.Reexport(ctx, record, stories, func(e scanApi.ProgressEvent) {
+			_ = progress.Set(exportProgress(e))
+			_ = status.Set(exportStatus(e))
+		})
+		cancelled := ctx.Err() != nil
+		progressDialog.Hide()
+		switch {
+		case cancelled:
+			dialog.ShowInformation("Export", "Export cancelled", a.RootWindow)
+		case errors.Is(err, services.ErrNoNewParts):
+			dialog.ShowInformation("Export", "No new parts have been scanned since the export was made", a.RootWindow)
+		case err != nil:
+			dialog.ShowError(err, a.RootWindow)
+		default:
+			if err := a.Services.Storage.SaveExport(updated); err != nil {
+				dialog.ShowError(err, a.RootWindow)
+			}
+			onDone()
+			dialog.ShowInformation("Export", exportSummary(report), a.RootWindow)
+		}
+	}()
+}
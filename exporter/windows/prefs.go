@@ -1,12 +1,17 @@
 package windows
 
 import (
+	"slices"
+	"strings"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
 	"github.com/chooban/progger/exporter/app"
+	"github.com/chooban/progger/exporter/services"
+	"github.com/chooban/progger/scan"
 )
 
 func newSettingsCanvas(a *app.ProggerApp) fyne.CanvasObject {
@@ -16,6 +21,10 @@ func newSettingsCanvas(a *app.ProggerApp) fyne.CanvasObject {
 		layout.NewVBoxLayout(),
 		directoriesContainer(a, a.RootWindow),
 		widget.NewSeparator(),
+		filenamesContainer(a),
+		widget.NewSeparator(),
+		duplicatesContainer(a),
+		widget.NewSeparator(),
 		rebellionContainer(fyneApp),
 	)
 
@@ -41,6 +50,52 @@ func rebellionContainer(a fyne.App) *fyne.Container {
 	return formContainer
 }
 
+func filenamesContainer(a *app.ProggerApp) *fyne.Container {
+	template := widget.NewEntryWithData(a.Services.Prefs.FilenameTemplate)
+	template.SetPlaceHolder(services.DefaultFilenameTemplate)
+
+	help := widget.NewLabel("Placeholders: " + strings.Join(services.FilenamePlaceholders, " ") +
+		"\nUse / to put exports in folders, e.g. {series}/{story} ({first}-{last})")
+	help.Wrapping = fyne.TextWrapWord
+
+	return container.New(
+		layout.NewVBoxLayout(),
+		widget.NewLabel("Export Filenames"),
+		container.New(layout.NewFormLayout(), widget.NewLabel("Template"), template),
+		help,
+	)
+}
+
+// duplicateChoices names the rules for picking which file to keep when a scan finds an issue more than once
+var duplicateChoices = map[string]scan.DuplicatePreference{
+	"Keep the newest file":  scan.PreferNewest,
+	"Keep the largest file": scan.PreferLargest,
+}
+
+func duplicatesContainer(a *app.ProggerApp) *fyne.Container {
+	prefs := a.Services.Prefs
+	choices := make([]string, 0, len(duplicateChoices))
+	selected := ""
+	for label, preference := range duplicateChoices {
+		choices = append(choices, label)
+		if preference == prefs.DuplicatePreference() {
+			selected = label
+		}
+	}
+	slices.Sort(choices)
+
+	choice := widget.NewSelect(choices, func(label string) {
+		_ = prefs.Duplicates.Set(duplicateChoices[label].String())
+	})
+	choice.SetSelected(selected)
+
+	return container.New(
+		layout.NewVBoxLayout(),
+		widget.NewLabel("Duplicate Issues"),
+		container.New(layout.NewFormLayout(), widget.NewLabel("When an issue is found twice"), choice),
+	)
+}
+
 func directoriesContainer(a *app.ProggerApp, w fyne.Window) *fyne.Container {
 	progSource := a.Services.Prefs.ProgSourceDir
 	megSource := a.Services.Prefs.MegazineSourceDir
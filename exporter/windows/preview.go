@@ -0,0 +1,108 @@
+package windows
+
+import (
+	"bytes"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/chooban/progger/exporter/api"
+	"github.com/chooban/progger/exporter/app"
+	scanApi "github.com/chooban/progger/scan/api"
+)
+
+// previewKey identifies a page of a source file
+type previewKey struct {
+	filename string
+	page     int
+}
+
+// showExportPreview shows a grid of the pages an export would contain, each with a checkbox to keep it or
+// leave it out. Pages the page filters would drop start unticked. When the preview is closed, the pages that
+// have been toggled are passed back as overrides, along with those passed in.
+func showExportPreview(a *app.ProggerApp, stories []*api.Story, options api.ExportOptions, onDone func([]scanApi.PageOverride)) {
+	ctx, cancel, _ := app.WithLogger()
+	progress := binding.NewFloat()
+	status := binding.NewString()
+	_ = status.Set("Reading pages")
+	options.Progress = func(e scanApi.ProgressEvent) {
+		_ = progress.Set(exportProgress(e))
+		_ = status.Set(exportStatus(e))
+	}
+
+	progressDialog := dialog.NewCustom("Previewing", "Cancel", container.NewVBox(
+		widget.NewLabelWithData(status),
+		widget.NewProgressBarWithData(progress),
+	), a.RootWindow)
+	progressDialog.SetOnClosed(cancel)
+	progressDialog.Resize(fyne.NewSize(400, 100))
+	progressDialog.Show()
+
+	go func() {
+		pages, err := a.Services.Exporter.Preview(ctx, stories, options)
+		cancelled := ctx.Err() != nil
+		progressDialog.Hide()
+		switch {
+		case cancelled:
+			return
+		case err != nil:
+			dialog.ShowError(err, a.RootWindow)
+			return
+		}
+
+		overrides := make(map[previewKey]bool)
+		for _, o := range options.PageOverrides {
+			overrides[previewKey{o.Filename, o.Page}] = o.Keep
+		}
+		d := dialog.NewCustom("Export Preview", "Done", container.NewVScroll(previewGrid(pages, overrides)), a.RootWindow)
+		d.SetOnClosed(func() {
+			result := make([]scanApi.PageOverride, 0, len(overrides))
+			for k, keep := range overrides {
+				result = append(result, scanApi.PageOverride{Filename: k.filename, Page: k.page, Keep: keep})
+			}
+			onDone(result)
+		})
+		d.Resize(fyne.NewSize(800, 600))
+		d.Show()
+	}()
+}
+
+// previewGrid lays out the thumbnails of each episode under its title. Ticking or unticking a page records
+// it in the overrides.
+func previewGrid(pages []scanApi.PreviewPage, overrides map[previewKey]bool) fyne.CanvasObject {
+	sections := container.NewVBox()
+	var grid *fyne.Container
+	episode := ""
+	for i, p := range pages {
+		if grid == nil || p.Episode != episode || p.Filename != pages[i-1].Filename {
+			episode = p.Episode
+			grid = container.NewGridWrap(fyne.NewSize(130, 230))
+			sections.Add(widget.NewLabelWithStyle(
+				fmt.Sprintf("%s (prog %d)", p.Episode, p.IssueNumber),
+				fyne.TextAlignLeading,
+				fyne.TextStyle{Bold: true},
+			))
+			sections.Add(grid)
+		}
+
+		thumbnail := canvas.NewImageFromReader(bytes.NewReader(p.Thumbnail), fmt.Sprintf("%d-%d.jpg", p.IssueNumber, p.Page))
+		thumbnail.FillMode = canvas.ImageFillContain
+		thumbnail.SetMinSize(fyne.NewSize(120, 170))
+
+		key := previewKey{p.Filename, p.Page}
+		keep := widget.NewCheck(fmt.Sprintf("Page %d", p.Page), func(keep bool) {
+			overrides[key] = keep
+		})
+		keep.Checked = !p.Dropped
+
+		reason := widget.NewLabel(p.Reason)
+		reason.Truncation = fyne.TextTruncateEllipsis
+		reason.TextStyle = fyne.TextStyle{Italic: true}
+		grid.Add(container.NewBorder(nil, container.NewVBox(keep, reason), nil, nil, thumbnail))
+	}
+	return sections
+}
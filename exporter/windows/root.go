@@ -8,13 +8,22 @@ import (
 )
 
 func TabWindow(a *app.ProggerApp) fyne.CanvasObject {
+	history, refreshHistory := newHistoryCanvas(a)
+	historyTab := container.NewTabItemWithIcon("History", theme.HistoryIcon(), history)
 	tabs := container.NewAppTabs(
 		container.NewTabItemWithIcon("Stories", theme.DocumentIcon(), newStoriesCanvas(a)),
+		historyTab,
 		container.NewTabItemWithIcon("Downloads", theme.DownloadIcon(), newDownloadsCanvas(a)),
 		container.NewTabItemWithIcon("Settings", theme.SettingsIcon(), newSettingsCanvas(a)),
 		container.NewTabItemWithIcon("About", theme.HomeIcon(), newAboutCanvas(a)),
 	)
 	tabs.SetTabLocation(container.TabLocationLeading)
+	// Exports made from the stories tab are added to the history, so it is reloaded whenever it is shown
+	tabs.OnSelected = func(t *container.TabItem) {
+		if t == historyTab {
+			refreshHistory()
+		}
+	}
 
 	return tabs
 }
@@ -0,0 +1,113 @@
+package windows
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/chooban/progger/exporter/api"
+	"github.com/chooban/progger/exporter/app"
+	scanApi "github.com/chooban/progger/scan/api"
+)
+
+const maxSearchResults = 200
+
+type searchResult struct {
+	Hit      scanApi.SearchHit
+	Selected bool
+}
+
+func searchButton(a *app.ProggerApp) *widget.Button {
+	return widget.NewButton("Search Text", func() {
+		showSearchDialog(a)
+	})
+}
+
+func showSearchDialog(a *app.ProggerApp) {
+	boundResults := binding.NewUntypedList()
+	status := widget.NewLabel("Search the dialogue and captions of scanned episodes")
+
+	resultsList := widget.NewListWithData(
+		boundResults,
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(
+				nil, nil, nil,
+				widget.NewCheck("", func(b bool) {}),
+				label,
+			)
+		},
+		func(di binding.DataItem, o fyne.CanvasObject) {
+			ctr, _ := o.(*fyne.Container)
+			label := ctr.Objects[0].(*widget.Label)
+			check := ctr.Objects[1].(*widget.Check)
+			diu, _ := di.(binding.Untyped).Get()
+			result := diu.(*searchResult)
+
+			hit := result.Hit
+			label.SetText(fmt.Sprintf("%s - %s, Part %d (Prog %d, p%d): %s", hit.Series, hit.Title, hit.Part, hit.IssueNumber, hit.Page, hit.Snippet))
+			check.Bind(binding.BindBool(&result.Selected))
+		},
+	)
+
+	query := widget.NewEntry()
+	query.SetPlaceHolder("e.g. I am the law")
+	runSearch := func(q string) {
+		hits, err := a.Services.Scanner.Search(q, maxSearchResults)
+		if err != nil {
+			dialog.ShowError(err, a.RootWindow)
+			return
+		}
+		results := make([]interface{}, len(hits))
+		for i, h := range hits {
+			results[i] = &searchResult{Hit: h}
+		}
+		if err := boundResults.Set(results); err != nil {
+			println(err.Error())
+		}
+		status.SetText(fmt.Sprintf("%d matching pages", len(hits)))
+	}
+	query.OnSubmitted = runSearch
+
+	content := container.NewBorder(
+		container.NewVBox(
+			container.NewBorder(nil, nil, nil, widget.NewButton("Search", func() {
+				runSearch(query.Text)
+			}), query),
+			status,
+		),
+		nil, nil, nil,
+		resultsList,
+	)
+
+	onClose := func(add bool) {
+		if !add {
+			return
+		}
+		results, _ := boundResults.Get()
+		stories, _ := a.State.Stories.Get()
+		for _, r := range results {
+			result := r.(*searchResult)
+			if !result.Selected {
+				continue
+			}
+			for _, v := range stories {
+				story := v.(*api.Story)
+				if story.Series == result.Hit.Series && story.Title == result.Hit.Title {
+					story.ToExport = true
+				}
+			}
+		}
+		if err := a.State.Stories.Set(stories); err != nil {
+			dialog.ShowError(err, a.RootWindow)
+		}
+	}
+
+	d := dialog.NewCustomConfirm("Search", "Add to Export", "Close", content, onClose, a.RootWindow)
+	d.Show()
+	d.Resize(fyne.NewSize(700, 600))
+}
@@ -3,7 +3,9 @@ package windows
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 
 	"fyne.io/fyne/v2"
@@ -14,6 +16,8 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/chooban/progger/exporter/api"
 	"github.com/chooban/progger/exporter/app"
+	"github.com/chooban/progger/exporter/services"
+	scanApi "github.com/chooban/progger/scan/api"
 )
 
 func showHide(container *fyne.Container, toShow fyne.CanvasObject) {
@@ -25,7 +29,7 @@ func showHide(container *fyne.Container, toShow fyne.CanvasObject) {
 
 func newStoriesCanvas(a *app.ProggerApp) fyne.CanvasObject {
 	storiesPanel := storiesContainer(a)
-	scannerProgress := newScannerProgressContainer()
+	scannerProgress := newScannerProgressContainer(a.State.ScanProgress)
 	downloadProgress := newDownloadProgressContainer()
 
 	centralLayout := container.New(
@@ -69,10 +73,11 @@ func newStoriesCanvas(a *app.ProggerApp) fyne.CanvasObject {
 	return storiesLayout
 }
 
-func newScannerProgressContainer() *fyne.Container {
+func newScannerProgressContainer(progress binding.String) *fyne.Container {
 	barContainer := container.NewVBox(
 		widget.NewProgressBarInfinite(),
 		widget.NewLabel("Scanning..."),
+		widget.NewLabelWithData(progress),
 	)
 	centeredBar := container.NewCenter(
 		barContainer,
@@ -167,6 +172,7 @@ func startScan(a *app.ProggerApp) {
 	dirsToScan := []string{a.Services.Prefs.ProgSourceDirectory(), a.Services.Prefs.MegSourceDirectory()}
 	knownTitles := a.Services.Storage.ReadKnownTitles()
 	skipTitles := a.Services.Storage.ReadSkipTitles()
+	duplicates := a.Services.Prefs.DuplicatePreference()
 
 	// Create the operation
 	op := app.NewScanOperation()
@@ -180,7 +186,13 @@ func startScan(a *app.ProggerApp) {
 			_ = op.IsRunning.Set(false)
 		}()
 
-		foundStories, err := a.Services.Scanner.Scan(ctx, dirsToScan, knownTitles, skipTitles)
+		scanned := 0
+		onIssue := func(issue scanApi.Issue) {
+			scanned++
+			_ = op.Progress.Set(fmt.Sprintf("Scanned %d issues. Last: %s %d", scanned, issue.Publication, issue.IssueNumber))
+		}
+
+		foundStories, err := a.Services.Scanner.Scan(ctx, dirsToScan, knownTitles, skipTitles, duplicates, onIssue)
 		if err != nil {
 			_ = op.Error.Set(err.Error())
 			return
@@ -211,6 +223,11 @@ func startScan(a *app.ProggerApp) {
 		a.State.IsScanning.Set(isRunning)
 	}))
 
+	op.Progress.AddListener(binding.NewDataListener(func() {
+		progress, _ := op.Progress.Get()
+		a.State.ScanProgress.Set(progress)
+	}))
+
 	op.Stories.AddListener(binding.NewDataListener(func() {
 		stories, _ := op.Stories.Get()
 		a.State.Stories.Set(stories)
@@ -260,6 +277,7 @@ func storiesButtonsContainer(a *app.ProggerApp) fyne.CanvasObject {
 
 	return container.NewVBox(
 		exportButton,
+		searchButton(a),
 		scanButton,
 	)
 }
@@ -284,34 +302,239 @@ func exportButton(a *app.ProggerApp) *widget.Button {
 			dialog.ShowInformation("Export", "No stories selected", a.RootWindow)
 		} else {
 			filename := binding.NewString()
-			filename.Set(toExport[0].Display() + ".pdf")
 			fnameEntry := widget.NewEntryWithData(filename)
 
 			artistBool := binding.NewBool()
 			artistCheckbox := widget.NewCheckWithData("", artistBool)
 
+			format := ".pdf"
+			template := prefsService.ExportFilenameTemplate()
+			suggested := ""
+			suggestFilename := func(artistsEdition bool) {
+				current, _ := filename.Get()
+				if current != suggested {
+					// A name typed in by hand is kept, with only its extension and edition following the options
+					filename.Set(exportFilename(current, format, artistsEdition))
+					return
+				}
+				values := services.NewFilenameValues(toExport, format, artistsEdition)
+				name, err := services.ExpandFilename(template, values)
+				if err != nil {
+					name, _ = services.ExpandFilename(services.DefaultFilenameTemplate, values)
+				}
+				suggested = name
+				filename.Set(name)
+			}
+
+			formatSelect := widget.NewSelect([]string{"PDF", "CBZ", "EPUB"}, func(v string) {
+				format = "." + strings.ToLower(v)
+				exportArtistEd, _ := artistBool.Get()
+				suggestFilename(exportArtistEd)
+			})
+			formatSelect.SetSelected("PDF")
+
 			artistCheckbox.OnChanged = func(v bool) {
-				_f, _ := filename.Get()
-				if v {
-					_f = strings.TrimSuffix(_f, ".pdf") + " - Artists Edition.pdf"
+				suggestFilename(v)
+				artistBool.Set(v)
+			}
+
+			orderNames := []string{api.OrderAsPublished.String(), api.OrderByStory.String(), api.OrderCustom.String()}
+			arrangeButton := widget.NewButton("Arrange...", func() {
+				showStoryOrder(a, toExport)
+			})
+			arrangeButton.Disable()
+			orderSelect := widget.NewSelect(orderNames, func(v string) {
+				if api.ExportOrder(slices.Index(orderNames, v)) == api.OrderCustom {
+					arrangeButton.Enable()
 				} else {
-					_f = strings.TrimSuffix(_f, " - Artists Edition.pdf") + ".pdf"
+					arrangeButton.Disable()
 				}
-				filename.Set(_f)
-				artistBool.Set(v)
+			})
+			orderSelect.SetSelected(orderNames[0])
+
+			reprintsBool := binding.NewBool()
+			reprintsBool.Set(true)
+			reprintsCheckbox := widget.NewCheckWithData("", reprintsBool)
+
+			progBookmarksBool := binding.NewBool()
+			progBookmarksCheckbox := widget.NewCheckWithData("", progBookmarksBool)
+
+			generatedPagesBool := binding.NewBool()
+			generatedPagesCheckbox := widget.NewCheckWithData("", generatedPagesBool)
+
+			keepSpreadsBool := binding.NewBool()
+			keepSpreadsCheckbox := widget.NewCheckWithData("Keep Originals", keepSpreadsBool)
+			keepSpreadsCheckbox.Disable()
+			joinSpreadsBool := binding.NewBool()
+			joinSpreadsCheckbox := widget.NewCheckWithData("", joinSpreadsBool)
+			joinSpreadsCheckbox.OnChanged = func(join bool) {
+				_ = joinSpreadsBool.Set(join)
+				if join {
+					keepSpreadsCheckbox.Enable()
+				} else {
+					keepSpreadsCheckbox.SetChecked(false)
+					keepSpreadsCheckbox.Disable()
+				}
+			}
+
+			volumeLimit := widget.NewEntry()
+			volumeLimit.Disable()
+			volumeSelect := widget.NewSelect(volumeSplitNames, func(v string) {
+				switch volumeSplit(v) {
+				case scanApi.SplitByPages:
+					volumeLimit.SetPlaceHolder("Pages per volume, e.g. 200")
+					volumeLimit.Enable()
+				case scanApi.SplitBySize:
+					volumeLimit.SetPlaceHolder("Megabytes per volume, e.g. 100")
+					volumeLimit.Enable()
+				default:
+					volumeLimit.SetPlaceHolder("")
+					volumeLimit.Disable()
+				}
+			})
+			volumeSelect.SetSelected("None")
+
+			printLayouts := []scanApi.PrintLayout{scanApi.PrintPages, scanApi.PrintBooklet, scanApi.PrintTwoUp}
+			printNames := make([]string, 0, len(printLayouts))
+			for _, l := range printLayouts {
+				printNames = append(printNames, l.String())
+			}
+			paperSizes := []string{"A3", "A4", "Tabloid", "Letter"}
+			paperSelect := widget.NewSelect(paperSizes, func(string) {})
+			paperSelect.SetSelected(paperSizes[0])
+			paperSelect.Disable()
+			printSelect := widget.NewSelect(printNames, func(selected string) {
+				if selected == printNames[0] {
+					paperSelect.Disable()
+				} else {
+					paperSelect.Enable()
+				}
+			})
+			printSelect.SetSelected(printNames[0])
+			cropMarksBool := binding.NewBool()
+			cropMarksCheckbox := widget.NewCheckWithData("Crop Marks", cropMarksBool)
+
+			scrollWidth := widget.NewEntry()
+			scrollWidth.SetPlaceHolder("Width in pixels, e.g. 1080")
+			scrollWidth.Disable()
+			scrollBool := binding.NewBool()
+			scrollCheckbox := widget.NewCheckWithData("", scrollBool)
+			scrollCheckbox.OnChanged = func(scroll bool) {
+				_ = scrollBool.Set(scroll)
+				if scroll {
+					scrollWidth.Enable()
+				} else {
+					scrollWidth.Disable()
+				}
+			}
+
+			panelsBool := binding.NewBool()
+			panelsCheckbox := widget.NewCheckWithData("", panelsBool)
+
+			profiles := scanApi.ExportProfiles()
+			profileNames := make([]string, 0, len(profiles))
+			for _, p := range profiles {
+				profileNames = append(profileNames, p.Name)
 			}
+			profileSelect := widget.NewSelect(profileNames, func(string) {})
+			profileSelect.SetSelected(profileNames[0])
+
+			// Pages kept or left out by hand in the preview
+			overrides := make([]scanApi.PageOverride, 0)
+			previewButton := widget.NewButton("Preview...", func() {
+				includeReprints, _ := reprintsBool.Get()
+				showExportPreview(a, toExport, api.ExportOptions{
+					IncludeReprints: includeReprints,
+					Order:           api.ExportOrder(max(0, slices.Index(orderNames, orderSelect.Selected))),
+					PageOverrides:   overrides,
+				}, func(o []scanApi.PageOverride) {
+					overrides = o
+				})
+			})
 
 			onClose := func(b bool) {
 				if b {
 					fname, _ := filename.Get()
 					exportArtistEd, _ := artistBool.Get()
+					includeReprints, _ := reprintsBool.Get()
+					progBookmarks, _ := progBookmarksBool.Get()
+					generatedPages, _ := generatedPagesBool.Get()
+					joinSpreads, _ := joinSpreadsBool.Get()
+					keepSpreads, _ := keepSpreadsBool.Get()
+					cropMarks, _ := cropMarksBool.Get()
+					panels, _ := panelsBool.Get()
+					options := api.ExportOptions{
+						ArtistsEdition:  exportArtistEd,
+						IncludeReprints: includeReprints,
+						Order:           api.ExportOrder(max(0, slices.Index(orderNames, orderSelect.Selected))),
+						ProgBookmarks:   progBookmarks,
+						GeneratedPages:  generatedPages,
+						Spreads:         scanApi.SpreadOptions{Join: joinSpreads, KeepOriginals: keepSpreads},
+						Panels:          panels,
+						PageOverrides:   overrides,
+						Print: scanApi.PrintOptions{
+							Layout:    printLayouts[max(0, slices.Index(printNames, printSelect.Selected))],
+							PaperSize: paperSelect.Selected,
+							CropMarks: cropMarks,
+						},
+					}
 
-					ctx, _, _ := app.WithLogger()
-					if err := exporter.Export(ctx, toExport, exportArtistEd, prefsService.ExportDirectory(), fname); err != nil {
+					volumes, err := volumeOptions(volumeSplit(volumeSelect.Selected), volumeLimit.Text)
+					if err != nil {
+						dialog.ShowError(err, a.RootWindow)
+						return
+					}
+					options.Volumes = volumes
+					scroll, err := scrollOptions(scrollCheckbox.Checked, scrollWidth.Text)
+					if err != nil {
 						dialog.ShowError(err, a.RootWindow)
-					} else {
-						dialog.ShowInformation("Export", "File successfully exported", a.RootWindow)
+						return
 					}
+					options.Scroll = scroll
+					if idx := slices.Index(profileNames, profileSelect.Selected); idx >= 0 {
+						options.Profile = profiles[idx]
+					}
+
+					ctx, cancel, logger := app.WithLogger()
+					progress := binding.NewFloat()
+					status := binding.NewString()
+					_ = status.Set("Starting export")
+					options.Progress = func(e scanApi.ProgressEvent) {
+						_ = progress.Set(exportProgress(e))
+						_ = status.Set(exportStatus(e))
+					}
+
+					progressDialog := dialog.NewCustom("Exporting", "Cancel", container.NewVBox(
+						widget.NewLabelWithData(status),
+						widget.NewProgressBarWithData(progress),
+					), a.RootWindow)
+					progressDialog.SetOnClosed(cancel)
+					progressDialog.Resize(fyne.NewSize(400, 100))
+					progressDialog.Show()
+
+					go func() {
+						report, err := exporter.Export(ctx, toExport, options, prefsService.ExportDirectory(), fname)
+						cancelled := ctx.Err() != nil
+						progressDialog.Hide()
+						switch {
+						case cancelled:
+							dialog.ShowInformation("Export", "Export cancelled", a.RootWindow)
+						case err != nil:
+							dialog.ShowError(err, a.RootWindow)
+						default:
+							template := ""
+							if fname == suggested {
+								template = prefsService.ExportFilenameTemplate()
+							}
+							record := services.NewExportRecord(toExport, options, prefsService.ExportDirectory(), fname, template, report)
+							summary := exportSummary(report)
+							if err := a.Services.Storage.SaveExport(record); err != nil {
+								logger.Error(err, "Failed to save export history", "file_name", fname)
+								summary += fmt.Sprintf("\n\nThe export couldn't be added to the history: %s", err)
+							}
+							dialog.ShowInformation("Export", summary, a.RootWindow)
+						}
+					}()
 				}
 			}
 
@@ -321,7 +544,19 @@ func exportButton(a *app.ProggerApp) *widget.Button {
 				"Cancel",
 				[]*widget.FormItem{
 					{Text: "Filename", Widget: fnameEntry},
+					{Text: "Format", Widget: formatSelect},
 					{Text: "Artists Edition", Widget: artistCheckbox},
+					{Text: "Include Reprints", Widget: reprintsCheckbox},
+					{Text: "Order", Widget: container.NewGridWithColumns(2, orderSelect, arrangeButton)},
+					{Text: "Pages", Widget: previewButton},
+					{Text: "Bookmark Progs", Widget: progBookmarksCheckbox},
+					{Text: "Title, Contents and Credits", Widget: generatedPagesCheckbox},
+					{Text: "Join Spreads", Widget: container.NewGridWithColumns(2, joinSpreadsCheckbox, keepSpreadsCheckbox)},
+					{Text: "Split Volumes", Widget: container.NewGridWithColumns(2, volumeSelect, volumeLimit)},
+					{Text: "Profile", Widget: profileSelect},
+					{Text: "Vertical Scroll", Widget: container.NewGridWithColumns(2, scrollCheckbox, scrollWidth)},
+					{Text: "Guided View Panels", Widget: panelsCheckbox},
+					{Text: "Print", Widget: container.NewGridWithColumns(3, printSelect, paperSelect, cropMarksCheckbox)},
 				},
 				onClose,
 				a.RootWindow,
@@ -334,6 +569,155 @@ func exportButton(a *app.ProggerApp) *widget.Button {
 	return exportButton
 }
 
+// showStoryOrder lets the stories be put in the order they should be exported in. The slice is reordered
+// in place.
+func showStoryOrder(a *app.ProggerApp, stories []*api.Story) {
+	selected := -1
+	list := widget.NewList(
+		func() int { return len(stories) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(fmt.Sprintf("%d. %s", i+1, stories[i].Display()))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) { selected = id }
+
+	move := func(by int) {
+		to := selected + by
+		if selected < 0 || to < 0 || to >= len(stories) {
+			return
+		}
+		stories[selected], stories[to] = stories[to], stories[selected]
+		list.Refresh()
+		list.Select(to)
+	}
+	buttons := container.NewHBox(
+		widget.NewButton("Move Up", func() { move(-1) }),
+		widget.NewButton("Move Down", func() { move(1) }),
+	)
+
+	d := dialog.NewCustom("Story Order", "Done", container.NewBorder(nil, buttons, nil, nil, list), a.RootWindow)
+	d.Resize(fyne.NewSize(450, 400))
+	d.Show()
+}
+
+// volumeSplitNames are the choices of how to split an export, in the order of scanApi.VolumeSplit
+var volumeSplitNames = []string{"None", "By Page Count", "By Size (MB)", "By Story"}
+
+func volumeSplit(name string) scanApi.VolumeSplit {
+	return scanApi.VolumeSplit(max(0, slices.Index(volumeSplitNames, name)))
+}
+
+// volumeOptions reads the volume limit entered for the chosen split
+func volumeOptions(split scanApi.VolumeSplit, limit string) (scanApi.VolumeOptions, error) {
+	options := scanApi.VolumeOptions{Split: split}
+	if split != scanApi.SplitByPages && split != scanApi.SplitBySize {
+		return options, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil || n <= 0 {
+		return options, fmt.Errorf("volume limit must be a positive number, not %q", limit)
+	}
+	if split == scanApi.SplitByPages {
+		options.MaxPages = n
+	} else {
+		options.MaxBytes = int64(n) * 1024 * 1024
+	}
+	return options, nil
+}
+
+// scrollOptions reads the width for a vertical scroll export, leaving it empty for the default
+func scrollOptions(enabled bool, width string) (scanApi.ScrollOptions, error) {
+	options := scanApi.ScrollOptions{Enabled: enabled}
+	width = strings.TrimSpace(width)
+	if !enabled || width == "" {
+		return options, nil
+	}
+	n, err := strconv.Atoi(width)
+	if err != nil || n <= 0 {
+		return options, fmt.Errorf("scroll width must be a positive number, not %q", width)
+	}
+	options.Width = n
+	return options, nil
+}
+
+// exportProgress is how far through the export the build is, counting each episode equally
+func exportProgress(e scanApi.ProgressEvent) float64 {
+	if e.Episodes == 0 || e.Stage == scanApi.ProgressFinishing {
+		return 1
+	}
+	done := e.Episode - 1
+	if e.Stage == scanApi.ProgressEpisodeFinished {
+		done = e.Episode
+	}
+	return float64(done) / float64(e.Episodes)
+}
+
+func exportStatus(e scanApi.ProgressEvent) string {
+	if e.Stage == scanApi.ProgressFinishing {
+		return "Saving..."
+	}
+	status := fmt.Sprintf("Episode %d of %d: %s", e.Episode, e.Episodes, e.Title)
+	if e.Stage == scanApi.ProgressPageAdded {
+		status += fmt.Sprintf(" (page %d)", e.Page)
+	}
+	return status
+}
+
+// exportSummary reports a successful export, with how much the profile saved, any pages the page filters
+// left out, and any problems found when the export was checked
+func exportSummary(report scanApi.BuildReport) string {
+	summary := "File successfully exported"
+	if len(report.Files) > 1 {
+		summary = fmt.Sprintf("Exported %d volumes", len(report.Files))
+	}
+	if report.Savings.Images > 0 {
+		saved, percent := report.Savings.Saved()
+		summary += fmt.Sprintf(" (images %.1f MB smaller, %.0f%%)", float64(saved)/(1024*1024), percent)
+	}
+
+	if len(report.Spreads) > 0 {
+		summary += fmt.Sprintf(", joining %d spreads", len(report.Spreads))
+	}
+	if report.Panels > 0 {
+		summary += fmt.Sprintf(", with %d panels for guided view", report.Panels)
+	}
+	if report.BlankPages > 0 {
+		summary += fmt.Sprintf(", with %d blank pages to fill the last sheet", report.BlankPages)
+	}
+
+	lines := []string{summary}
+	if len(report.Dropped) > 0 {
+		lines[0] = fmt.Sprintf("%s, leaving out %d pages:", summary, len(report.Dropped))
+		for _, d := range report.Dropped {
+			lines = append(lines, fmt.Sprintf("Prog %d, page %d: %s", d.IssueNumber, d.Page, d.Reason))
+		}
+	}
+	for _, v := range report.Validation {
+		if v.OK() {
+			continue
+		}
+		lines = append(lines, "", fmt.Sprintf("%s may be damaged:", filepath.Base(v.File)))
+		for _, p := range v.Problems {
+			lines = append(lines, fmt.Sprintf("%s: %s", p.Check, p.Message))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+const artistsEditionSuffix = " - Artists Edition"
+
+// exportFilename gives the filename the extension of the chosen format, adding or removing the artist's
+// edition suffix as needed.
+func exportFilename(current, ext string, artistsEdition bool) string {
+	base := strings.TrimSuffix(current, filepath.Ext(current))
+	base = strings.TrimSuffix(base, artistsEditionSuffix)
+	if artistsEdition {
+		base += artistsEditionSuffix
+	}
+	return base + ext
+}
+
 func ContainsAll(s string, t []string) bool {
 	if len(t) == 0 {
 		return true
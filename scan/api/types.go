@@ -2,6 +2,7 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 )
 
@@ -13,6 +14,10 @@ type Episode struct {
 	FirstPage int
 	LastPage  int
 	Credits   Credits
+	// Reprint is set for episodes that have been published before, such as "Judge Dredd Classic"
+	Reprint bool
+	// OriginalIssue is the issue a reprint first appeared in, when the reprint says so, otherwise zero
+	OriginalIssue int
 }
 
 type Issue struct {
@@ -20,6 +25,18 @@ type Issue struct {
 	IssueNumber int
 	Episodes    []*Episode
 	Filename    string
+	Pages       []PageText
+	// Hash is the SHA-256 of the file's contents
+	Hash string
+	// Duplicates lists files holding the same issue which were dropped in favour of this one
+	Duplicates []string
+}
+
+// A PageText holds the text extracted from a single page of an issue. Page numbers are one-indexed, as
+// with episode page ranges.
+type PageText struct {
+	Page int
+	Text string
 }
 
 type Creator struct {
@@ -68,10 +85,408 @@ func (r Role) String() string {
 	return ""
 }
 
+// An ExportPage is a range of pages from a source file to include in an export. Title is used for the
+// range's bookmark, while the remaining details describe the episode for formats that carry metadata.
 type ExportPage struct {
 	Filename    string
+	Publication string
+	IssueNumber int
+	Title       string
+	Series      string
+	Story       string
+	Part        int
+	Credits     Credits
+	PageFrom    int
+	PageTo      int
+}
+
+// An ExportSource records where a range of pages in an export came from. Exported PDFs carry a list of
+// these so that they can be identified later.
+type ExportSource struct {
+	Publication string
 	IssueNumber int
 	Title       string
 	PageFrom    int
 	PageTo      int
 }
+
+// BuildOptions controls how an export is built
+type BuildOptions struct {
+	// ArtistsEdition strips the lettering from pages, leaving only the artwork
+	ArtistsEdition bool
+	// ProgBookmarks adds the source prog beneath each part in the bookmarks
+	ProgBookmarks bool
+	// TitlePage starts a PDF export with a page naming the story and prog range
+	TitlePage bool
+	// ContentsPage adds a table of contents after the title page of a PDF export
+	ContentsPage bool
+	// CreditsPage ends a PDF export with the creators of each part
+	CreditsPage bool
+	// PageFilters decide which pages are left out of the export. When nil, DefaultPageFilters are used. An
+	// empty slice keeps every page.
+	PageFilters []PageFilterRule
+	// PageOverrides keep or leave out single pages, whatever the page filters decide, as chosen in an export
+	// preview
+	PageOverrides []PageOverride
+	// Profile resamples the page images to suit the device the export will be read on. The zero value keeps
+	// the original images.
+	Profile ExportProfile
+	// Spreads controls whether double-page spreads are joined onto a single wide page
+	Spreads SpreadOptions
+	// Print lays a PDF export out on sheets of paper for printing
+	Print PrintOptions
+	// Scroll stitches each episode's pages into tall strips, to be read by scrolling down on a phone
+	Scroll ScrollOptions
+	// Panels finds the panels on each page, so that guided-view readers can step through them. They are
+	// recorded in an ACBF file in a CBZ export, and aren't looked for in other formats or in a vertical
+	// scroll.
+	Panels bool
+	// Progress, when set, is called as each episode and page is exported
+	Progress func(ProgressEvent)
+}
+
+// SpreadOptions controls how double-page spreads, split across two pages of the source, are exported
+type SpreadOptions struct {
+	// Join puts each spread that is found onto a single wide page
+	Join bool
+	// KeepOriginals follows each joined spread with the two pages it was joined from
+	KeepOriginals bool
+}
+
+// PrintLayout is how the pages of a PDF export are arranged on printed sheets
+type PrintLayout int64
+
+const (
+	// PrintPages leaves each page on a sheet of its own
+	PrintPages PrintLayout = iota
+	// PrintBooklet imposes the pages as a saddle-stitched booklet, two to each side of a sheet, ordered so
+	// that the printed sheets can be stacked, folded down the middle and stapled
+	PrintBooklet
+	// PrintTwoUp puts two pages side by side on each sheet, in reading order
+	PrintTwoUp
+)
+
+func (l PrintLayout) String() string {
+	switch l {
+	case PrintPages:
+		return "Pages"
+	case PrintBooklet:
+		return "Booklet"
+	case PrintTwoUp:
+		return "2-up"
+	}
+	return ""
+}
+
+// PrintOptions lays out a PDF export for printing
+type PrintOptions struct {
+	Layout PrintLayout
+	// PaperSize names the sheets the pages are imposed on, such as "A3" or "Tabloid", and is turned
+	// landscape to take two pages side by side. It defaults to A3.
+	PaperSize string
+	// CropMarks surrounds each page with a margin holding marks to trim it along. The progs are already
+	// trimmed, so there is no artwork beyond the marks to bleed into.
+	CropMarks bool
+}
+
+// ScrollOptions turns an export into a vertical scroll. A CBZ or EPUB gets tall images, and a PDF gets tall
+// pages, each holding as many of an episode's pages as fit, scaled to the same width. Each episode starts a
+// new strip, headed by its title.
+type ScrollOptions struct {
+	Enabled bool
+	// Width is the width, in pixels, that pages are scaled to. It defaults to 1080.
+	Width int
+}
+
+// ProgressStage is the point an export has reached
+type ProgressStage int64
+
+const (
+	// ProgressEpisodeStarted is sent before an episode's pages are read
+	ProgressEpisodeStarted ProgressStage = iota
+	// ProgressPageAdded is sent for each page added to the export
+	ProgressPageAdded
+	// ProgressEpisodeFinished is sent once an episode's pages have all been added
+	ProgressEpisodeFinished
+	// ProgressFinishing is sent once every episode is done, while the file is saved and finished off
+	ProgressFinishing
+)
+
+func (s ProgressStage) String() string {
+	switch s {
+	case ProgressEpisodeStarted:
+		return "episode started"
+	case ProgressPageAdded:
+		return "page added"
+	case ProgressEpisodeFinished:
+		return "episode finished"
+	case ProgressFinishing:
+		return "finishing"
+	}
+	return ""
+}
+
+// A ProgressEvent reports how far an export has got
+type ProgressEvent struct {
+	Stage ProgressStage
+	// Episode is the number of the episode being exported, counting from one, out of Episodes
+	Episode  int
+	Episodes int
+	Title    string
+	// Filename and Page are the source of the page just added, for ProgressPageAdded
+	Filename string
+	Page     int
+	// PagesAdded is the number of pages in the export so far
+	PagesAdded int
+}
+
+// A PageError is an export failing on a source file. Page is zero when the file as a whole couldn't be
+// used, such as when it can't be opened.
+type PageError struct {
+	Filename string
+	Page     int
+	Err      error
+}
+
+func (e *PageError) Error() string {
+	if e.Page == 0 {
+		return fmt.Sprintf("%s: %s", e.Filename, e.Err)
+	}
+	return fmt.Sprintf("page %d of %s: %s", e.Page, e.Filename, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// A PageClass is the broad kind of content on a page
+type PageClass int64
+
+const (
+	PageUnclassified PageClass = iota
+	PageStory
+	PageAdvert
+	PageLetters
+)
+
+func (c PageClass) String() string {
+	switch c {
+	case PageUnclassified:
+		return "unclassified"
+	case PageStory:
+		return "story"
+	case PageAdvert:
+		return "advert"
+	case PageLetters:
+		return "letters"
+	}
+	return ""
+}
+
+// A PageFilterRule drops pages from an export. A rule can match on the page's text, its classification, or
+// its resemblance to known images such as house ads. When more than one is set, any of them matching is
+// enough.
+type PageFilterRule struct {
+	Name string
+	// Text is a regular expression matched against the page's text, ignoring case
+	Text string
+	// Classification drops pages classified as this. PageUnclassified matches nothing.
+	Classification PageClass
+	// Images are image files of pages, such as house ads, that should be dropped wherever they appear
+	Images []string
+	// Similarity is how different, from 0 to 64, a page may be from one of the Images and still match.
+	// Zero uses a default.
+	Similarity int
+	// TrailingOnly limits the rule to the run of pages at the end of an episode. The first page of an
+	// episode is never dropped by a trailing rule.
+	TrailingOnly bool
+}
+
+// DefaultPageFilters drops the adverts that often follow the last page of an episode
+func DefaultPageFilters() []PageFilterRule {
+	return []PageFilterRule{
+		{
+			Name:         "Trailing adverts",
+			Text:         `on sale now|on sale \d{1,2} \w+ \d{4}`,
+			TrailingOnly: true,
+		},
+	}
+}
+
+// VolumeSplit decides where a large export is broken into separate volumes
+type VolumeSplit int64
+
+const (
+	// SplitNone exports everything to a single file
+	SplitNone VolumeSplit = iota
+	// SplitByPages starts a new volume before one would exceed the maximum number of pages
+	SplitByPages
+	// SplitBySize starts a new volume before one would exceed the maximum file size
+	SplitBySize
+	// SplitByStory puts each story in its own volume
+	SplitByStory
+)
+
+func (v VolumeSplit) String() string {
+	switch v {
+	case SplitNone:
+		return "none"
+	case SplitByPages:
+		return "pages"
+	case SplitBySize:
+		return "size"
+	case SplitByStory:
+		return "story"
+	}
+	return ""
+}
+
+// VolumeOptions controls how an export is split into volumes
+type VolumeOptions struct {
+	Split VolumeSplit
+	// MaxPages is the most pages a volume may have when splitting by pages
+	MaxPages int
+	// MaxBytes is the largest a volume may be when splitting by size. Sizes are estimated from the source
+	// files, so volumes may come out a little over or under.
+	MaxBytes int64
+}
+
+// An ExportProfile downscales and recompresses page images, to make exports small enough for phones and
+// e-readers. Images are only ever made smaller, never enlarged.
+type ExportProfile struct {
+	Name string
+	// MaxWidth and MaxHeight are the largest an image may be, in pixels. Zero means no limit.
+	MaxWidth  int
+	MaxHeight int
+	// Quality is the JPEG quality images are recompressed at. Zero keeps the original images.
+	Quality   int
+	Greyscale bool
+}
+
+// IsOriginal reports whether the profile leaves images as they are
+func (p ExportProfile) IsOriginal() bool {
+	return p.Quality == 0
+}
+
+// ExportProfiles are the built-in profiles, starting with the one that keeps the original images
+func ExportProfiles() []ExportProfile {
+	return []ExportProfile{
+		{Name: "Original"},
+		{Name: "Tablet", MaxWidth: 1600, MaxHeight: 2400, Quality: 80},
+		{Name: "E-ink Greyscale", MaxWidth: 1264, MaxHeight: 1680, Quality: 75, Greyscale: true},
+	}
+}
+
+// ImageSavings totals how much a profile shrank the images of an export
+type ImageSavings struct {
+	Images        int
+	OriginalBytes int64
+	ExportedBytes int64
+}
+
+// Saved returns the bytes saved, and that as a percentage of the original size
+func (s ImageSavings) Saved() (int64, float64) {
+	saved := s.OriginalBytes - s.ExportedBytes
+	if s.OriginalBytes == 0 {
+		return saved, 0
+	}
+	return saved, 100 * float64(saved) / float64(s.OriginalBytes)
+}
+
+// A PageOverride keeps a page of a source file in an export, or leaves it out, overruling the page filters
+type PageOverride struct {
+	Filename string
+	Page     int
+	Keep     bool
+}
+
+// A PreviewPage is a page of a planned export, or one that will be left out of it
+type PreviewPage struct {
+	Filename    string
+	IssueNumber int
+	Page        int
+	// Episode is the title of the episode the page is from
+	Episode string
+	// Dropped is set on a page that will be left out, with the reason why
+	Dropped bool
+	Reason  string
+	// Thumbnail is a small JPEG of the page
+	Thumbnail []byte
+}
+
+// A DroppedPage is a page that a filter left out of an export
+type DroppedPage struct {
+	Filename    string
+	IssueNumber int
+	Page        int
+	Rule        string
+	Reason      string
+}
+
+// A JoinedSpread is a double-page spread that was put onto a single page. Page is its left-hand page.
+type JoinedSpread struct {
+	Filename    string
+	IssueNumber int
+	Page        int
+}
+
+// A BuildReport describes what happened during an export
+type BuildReport struct {
+	// Files are the files written by the export, one per volume
+	Files []string
+	// Pages is the number of pages exported, counted before any print layout puts them onto sheets
+	Pages   int
+	Dropped []DroppedPage
+	// Savings is how much smaller the export profile made the page images
+	Savings ImageSavings
+	// Spreads are the double-page spreads that were joined
+	Spreads []JoinedSpread
+	// Panels is the number of panels found for guided view
+	Panels int
+	// BlankPages are the blank pages added to the end of a print layout to fill its last sheet
+	BlankPages int
+	// Validation holds the checks made on each PDF written by the export
+	Validation []ValidationReport
+}
+
+// A ValidationReport is the result of checking a finished PDF export
+type ValidationReport struct {
+	File          string
+	Pages         int
+	ExpectedPages int
+	Bookmarks     int
+	Problems      []ValidationProblem
+}
+
+// OK reports whether the export passed every check
+func (r ValidationReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// A ValidationProblem is a check that an export failed
+type ValidationProblem struct {
+	// Check is the check that failed: "structure", "page count" or "bookmarks"
+	Check   string
+	Message string
+}
+
+// A SearchHit is a single page matching a full-text search, along with the episode the page belongs to.
+type SearchHit struct {
+	Publication string
+	IssueNumber int
+	Filename    string
+	Series      string
+	Title       string
+	Part        int
+	Page        int
+	Snippet     string
+	Score       int
+}
+
+// A BookmarkChange is a bookmark in a source PDF whose title differs from the canonical one built from the
+// scan.
+type BookmarkChange struct {
+	Page int
+	From string
+	To   string
+}
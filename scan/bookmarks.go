@@ -0,0 +1,105 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/chooban/progger/scan/internal"
+	"github.com/go-logr/logr"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// BackupSuffix is appended to a source PDF's filename to name the copy made before its bookmarks are
+// rewritten.
+const BackupSuffix = ".bak"
+
+// RewriteBookmarks replaces the titles of the bookmarks in an issue's source PDF with the canonical series,
+// title and part of each episode, so that other reader apps show the sanitised names. Bookmarks that don't
+// start an episode, such as the cover, are left alone.
+//
+// Before the file is changed a backup is written alongside it, unless one already exists so that the
+// original is never lost. With dryRun set nothing is written and the changes that would be made are
+// returned.
+func RewriteBookmarks(ctx context.Context, issue api.Issue, dryRun bool) ([]api.BookmarkChange, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	bookmarks, err := internal.ReadBookmarks(issue.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading bookmarks from %s: %w", issue.Filename, err)
+	}
+
+	changes := renameBookmarks(bookmarks, issue.Episodes)
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	backup := issue.Filename + BackupSuffix
+	if _, err := os.Stat(backup); errors.Is(err, os.ErrNotExist) {
+		logger.Info("Backing up file", "file", issue.Filename, "backup", backup)
+		if err := copyFile(issue.Filename, backup); err != nil {
+			return nil, fmt.Errorf("backing up %s: %w", issue.Filename, err)
+		}
+	}
+
+	logger.Info("Rewriting bookmarks", "file", issue.Filename, "changes", len(changes))
+	if err := internal.ReplaceBookmarks(issue.Filename, bookmarks); err != nil {
+		return nil, fmt.Errorf("writing bookmarks to %s: %w", issue.Filename, err)
+	}
+
+	return changes, nil
+}
+
+// CanonicalBookmarkTitle is the bookmark title for an episode, in a form that scans back to the same series,
+// title and part.
+func CanonicalBookmarkTitle(episode *api.Episode) string {
+	title := fmt.Sprintf("%s: %s - Part %d", episode.Series, episode.Title, episode.Part)
+	if episode.Series == episode.Title {
+		title = fmt.Sprintf("%s - Part %d", episode.Series, episode.Part)
+	}
+	if episode.Reprint {
+		title += " (Reprint)"
+	}
+	return title
+}
+
+// renameBookmarks updates, in place, the title of each top level bookmark that starts on the first page of an
+// episode, returning what was changed.
+func renameBookmarks(bookmarks []pdfcpu.Bookmark, episodes []*api.Episode) []api.BookmarkChange {
+	changes := make([]api.BookmarkChange, 0)
+	for i, b := range bookmarks {
+		for _, e := range episodes {
+			if e.FirstPage != b.PageFrom {
+				continue
+			}
+			title := CanonicalBookmarkTitle(e)
+			if title != b.Title {
+				changes = append(changes, api.BookmarkChange{Page: b.PageFrom, From: b.Title, To: title})
+				bookmarks[i].Title = title
+			}
+			break
+		}
+	}
+	return changes
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
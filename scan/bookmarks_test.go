@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalBookmarkTitle(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		episode  api.Episode
+		expected string
+	}{
+		{
+			name:     "Series and title",
+			episode:  api.Episode{Series: "Judge Dredd", Title: "Get Sin", Part: 2},
+			expected: "Judge Dredd: Get Sin - Part 2",
+		},
+		{
+			name:     "Eponymous",
+			episode:  api.Episode{Series: "Hunted", Title: "Hunted", Part: 4},
+			expected: "Hunted - Part 4",
+		},
+		{
+			name:     "Reprint",
+			episode:  api.Episode{Series: "Judge Dredd", Title: "The Cursed Earth", Part: 3, Reprint: true},
+			expected: "Judge Dredd: The Cursed Earth - Part 3 (Reprint)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, CanonicalBookmarkTitle(&tc.episode))
+		})
+	}
+}
+
+func TestRenameBookmarks(t *testing.T) {
+	t.Parallel()
+	bookmarks := []pdfcpu.Bookmark{
+		{Title: "Cover", PageFrom: 1},
+		{Title: "Judge Dred - Get Sin - Pt 2", PageFrom: 3},
+		{Title: "Brink: Hate Box - Part 1", PageFrom: 9},
+	}
+	episodes := []*api.Episode{
+		{Series: "Judge Dredd", Title: "Get Sin", Part: 2, FirstPage: 3, LastPage: 8},
+		{Series: "Brink", Title: "Hate Box", Part: 1, FirstPage: 9, LastPage: 14},
+	}
+
+	changes := renameBookmarks(bookmarks, episodes)
+
+	assert.Equal(t, []api.BookmarkChange{
+		{Page: 3, From: "Judge Dred - Get Sin - Pt 2", To: "Judge Dredd: Get Sin - Part 2"},
+	}, changes)
+	assert.Equal(t, "Cover", bookmarks[0].Title)
+	assert.Equal(t, "Judge Dredd: Get Sin - Part 2", bookmarks[1].Title)
+	assert.Equal(t, "Brink: Hate Box - Part 1", bookmarks[2].Title)
+}
@@ -0,0 +1,136 @@
+package scan
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
+)
+
+// DuplicatePreference decides which file is kept when the same issue is found more than once.
+type DuplicatePreference int64
+
+const (
+	// PreferNewest keeps the most recently modified file
+	PreferNewest DuplicatePreference = iota
+	// PreferLargest keeps the largest file
+	PreferLargest
+)
+
+func (d DuplicatePreference) String() string {
+	switch d {
+	case PreferNewest:
+		return "newest"
+	case PreferLargest:
+		return "largest"
+	}
+	return ""
+}
+
+// ParseDuplicatePreference returns the preference named by String. "pdf" names the rule that used to be the
+// default, which kept a PDF over a CBZ and then the newest file. Only PDFs are scanned, so it is the same as
+// PreferNewest.
+func ParseDuplicatePreference(name string) (DuplicatePreference, error) {
+	switch strings.ToLower(name) {
+	case PreferNewest.String(), "pdf":
+		return PreferNewest, nil
+	case PreferLargest.String():
+		return PreferLargest, nil
+	}
+	return PreferNewest, fmt.Errorf("unknown duplicate preference %q", name)
+}
+
+type duplicateCandidate struct {
+	issue   api.Issue
+	size    int64
+	modTime time.Time
+}
+
+// removeDuplicates groups issues that share a publication and issue number, or whose files have the same
+// content, and keeps one per group according to the preference. The filenames of the files that were
+// dropped are recorded in the kept issue's Duplicates.
+func removeDuplicates(logger logr.Logger, issues []api.Issue, preference DuplicatePreference) []api.Issue {
+	groups := make([][]duplicateCandidate, 0, len(issues))
+	for _, issue := range issues {
+		candidate := duplicateCandidate{issue: issue}
+		if info, err := os.Stat(issue.Filename); err == nil {
+			candidate.size = info.Size()
+			candidate.modTime = info.ModTime()
+		}
+
+		idx := slices.IndexFunc(groups, func(g []duplicateCandidate) bool {
+			return slices.ContainsFunc(g, func(c duplicateCandidate) bool {
+				samePublication := c.issue.Publication == issue.Publication && c.issue.IssueNumber == issue.IssueNumber
+				sameContent := c.issue.Hash != "" && c.issue.Hash == issue.Hash
+				return samePublication || sameContent
+			})
+		})
+		if idx < 0 {
+			groups = append(groups, []duplicateCandidate{candidate})
+		} else {
+			groups[idx] = append(groups[idx], candidate)
+		}
+	}
+
+	deduped := make([]api.Issue, 0, len(groups))
+	for _, group := range groups {
+		if len(group) == 1 {
+			deduped = append(deduped, group[0].issue)
+			continue
+		}
+		slices.SortFunc(group, func(a, b duplicateCandidate) int {
+			return compareCandidates(a, b, preference)
+		})
+		preferred := group[0].issue
+		for _, c := range group[1:] {
+			preferred.Duplicates = append(preferred.Duplicates, c.issue.Filename)
+		}
+		logger.Info("Found duplicate issue",
+			"publication", preferred.Publication,
+			"issue_number", preferred.IssueNumber,
+			"preferred", preferred.Filename,
+			"duplicates", preferred.Duplicates,
+		)
+		deduped = append(deduped, preferred)
+	}
+
+	return deduped
+}
+
+// compareCandidates sorts the preferred candidate first. Ties are broken on filename so that results are
+// stable between scans.
+func compareCandidates(a, b duplicateCandidate, preference DuplicatePreference) int {
+	var c int
+	switch preference {
+	case PreferNewest:
+		c = b.modTime.Compare(a.modTime)
+	case PreferLargest:
+		c = cmp.Compare(b.size, a.size)
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(a.issue.Filename, b.issue.Filename)
+}
+
+func hashFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", filename, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveDuplicates(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+		modTime := time.Now().Add(-age)
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+		return path
+	}
+
+	oldLarge := writeFile("2000AD 2300 (1977).pdf", 200, 48*time.Hour)
+	newSmall := writeFile("PRG2300D.pdf", 100, time.Hour)
+	middling := writeFile("2000 AD 2300.pdf", 150, 24*time.Hour)
+	other := writeFile("2000AD 2301 (1977).pdf", 100, time.Hour)
+
+	issues := func() []api.Issue {
+		return []api.Issue{
+			{Publication: "2000 AD", IssueNumber: 2300, Filename: oldLarge, Hash: "a"},
+			{Publication: "2000 AD", IssueNumber: 2300, Filename: newSmall, Hash: "b"},
+			{Publication: "2000 AD", IssueNumber: 2300, Filename: middling, Hash: "c"},
+			{Publication: "2000 AD", IssueNumber: 2301, Filename: other, Hash: "d"},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		preference        DuplicatePreference
+		expectedPreferred string
+	}{
+		{
+			name:              "Newest",
+			preference:        PreferNewest,
+			expectedPreferred: newSmall,
+		},
+		{
+			name:              "Largest",
+			preference:        PreferLargest,
+			expectedPreferred: oldLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			deduped := removeDuplicates(logr.Discard(), issues(), tc.preference)
+
+			assert.Len(t, deduped, 2)
+			assert.Equal(t, tc.expectedPreferred, deduped[0].Filename)
+			assert.Len(t, deduped[0].Duplicates, 2)
+			assert.NotContains(t, deduped[0].Duplicates, tc.expectedPreferred)
+			assert.Empty(t, deduped[1].Duplicates)
+		})
+	}
+}
+
+func TestRemoveDuplicates_SameContent(t *testing.T) {
+	t.Parallel()
+	issues := []api.Issue{
+		{Publication: "2000 AD", IssueNumber: 2300, Filename: "b.pdf", Hash: "abc"},
+		{Publication: "2000 AD", IssueNumber: 230, Filename: "a.pdf", Hash: "abc"},
+	}
+
+	deduped := removeDuplicates(logr.Discard(), issues, PreferNewest)
+
+	assert.Len(t, deduped, 1)
+	assert.Equal(t, "a.pdf", deduped[0].Filename)
+	assert.Equal(t, []string{"b.pdf"}, deduped[0].Duplicates)
+}
+
+func TestParseDuplicatePreference(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name          string
+		expected      DuplicatePreference
+		expectedError bool
+	}{
+		{name: "newest", expected: PreferNewest},
+		{name: "largest", expected: PreferLargest},
+		{name: "Largest", expected: PreferLargest},
+		{name: "pdf", expected: PreferNewest},
+		{name: "smallest", expected: PreferNewest, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			preference, err := ParseDuplicatePreference(tc.name)
+			assert.Equal(t, tc.expected, preference)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ func main() {
 	file := parser.String("f", "file", &argparse.Options{Required: true, Help: "File to scan"})
 	pageFrom := parser.Int("s", "start", &argparse.Options{Required: true, Help: "Page to export from"})
 	pageTo := parser.Int("e", "end", &argparse.Options{Required: true, Help: "Page to export to"})
+	output := parser.String("o", "output", &argparse.Options{Default: "export.pdf", Help: "File to export to, ending in .pdf, .cbz or .epub"})
 
 	if err := parser.Parse(os.Args); err != nil {
 		fmt.Print(parser.Usage(err))
@@ -46,9 +47,12 @@ func main() {
 		},
 	}
 
-	err := scan.Build(ctx, pages, false, "export.pdf")
+	report, err := scan.Build(ctx, pages, api.BuildOptions{}, *output)
 	if err != nil {
 		log.Error(err, "Failed to export")
 	}
+	for _, d := range report.Dropped {
+		fmt.Printf("Dropped page %d: %s\n", d.Page, d.Reason)
+	}
 
 }
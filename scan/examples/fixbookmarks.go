@@ -0,0 +1,57 @@
+//go:build tools
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/akamensky/argparse"
+	"github.com/chooban/progger/scan"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zerologr"
+	"github.com/rs/zerolog"
+)
+
+func main() {
+	parser := argparse.NewParser("fixbookmarks", "Rewrites a prog's bookmarks with the titles found by scanning it")
+	f := parser.String("f", "file", &argparse.Options{Required: true, Help: "File to fix"})
+	dryRun := parser.Flag("n", "dry-run", &argparse.Options{Required: false, Help: "Show the changes without writing them"})
+
+	if err := parser.Parse(os.Args); err != nil {
+		fmt.Print(parser.Usage(err))
+		os.Exit(1)
+	}
+
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	writer := zerolog.ConsoleWriter{
+		Out:        os.Stderr,
+		TimeFormat: time.RFC3339,
+	}
+	logger := zerolog.New(writer)
+	var log = zerologr.New(&logger)
+
+	ctx := logr.NewContext(context.Background(), log)
+
+	scanner := scan.NewScanner([]string{}, []string{})
+	issue, err := scanner.File(ctx, *f)
+	if err != nil {
+		log.Error(err, "Failed to scan file")
+		os.Exit(1)
+	}
+
+	changes, err := scan.RewriteBookmarks(ctx, issue, *dryRun)
+	if err != nil {
+		log.Error(err, "Failed to rewrite bookmarks")
+		os.Exit(1)
+	}
+
+	for _, c := range changes {
+		fmt.Printf("page %d\n- %s\n+ %s\n", c.Page, c.From, c.To)
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes")
+	}
+}
@@ -0,0 +1,61 @@
+//go:build tools
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/akamensky/argparse"
+	"github.com/chooban/progger/scan"
+	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zerologr"
+	"github.com/rs/zerolog"
+)
+
+func main() {
+	parser := argparse.NewParser("scandir", "Scans a directory of progs, printing each as it is read")
+	d := parser.String("d", "directory", &argparse.Options{Required: true, Help: "Directory to scan"})
+	c := parser.Int("c", "count", &argparse.Options{Required: false, Help: "Number of issues to scan"})
+
+	if err := parser.Parse(os.Args); err != nil {
+		fmt.Print(parser.Usage(err))
+		os.Exit(1)
+	}
+
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	writer := zerolog.ConsoleWriter{
+		Out:        os.Stderr,
+		TimeFormat: time.RFC3339,
+	}
+	logger := zerolog.New(writer)
+	var log = zerologr.New(&logger)
+
+	ctx := logr.NewContext(context.Background(), log)
+
+	scanner := scan.NewScanner([]string{}, []string{})
+	results, err := scanner.Stream(ctx, *d, *c)
+	if err != nil {
+		log.Error(err, "Failed to scan directory")
+		os.Exit(1)
+	}
+
+	issues := make([]api.Issue, 0)
+	for r := range results {
+		if r.Err != nil {
+			log.Error(r.Err, "Failed to scan file", "file", r.Issue.Filename)
+			continue
+		}
+		fmt.Printf("%s %d: %d episodes\n", r.Issue.Publication, r.Issue.IssueNumber, len(r.Issue.Episodes))
+		issues = append(issues, r.Issue)
+	}
+
+	for _, issue := range scanner.Finalise(ctx, issues) {
+		for _, d := range issue.Duplicates {
+			fmt.Printf("%s %d: ignored duplicate %s\n", issue.Publication, issue.IssueNumber, d)
+		}
+	}
+}
@@ -3,19 +3,52 @@ package scan
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/chooban/progger/scan/api"
 	"github.com/chooban/progger/scan/internal"
+	"github.com/go-logr/logr"
 )
 
-// Build exports a PDF of the pages passed to it
-func Build(ctx context.Context, pages []api.ExportPage, artistsEdition bool, fileName string) error {
-	if !strings.HasSuffix(fileName, "pdf") {
-		return fmt.Errorf("file name must end with 'pdf'")
+// Build exports the pages passed to it. The format is chosen by the file name's extension: either a PDF, a CBZ
+// of page images with a ComicInfo.xml, or a fixed-layout EPUB. The report lists the pages that the page
+// filters left out. Cancelling the context stops the build, and a failed build leaves no file behind.
+// Errors reading a source file are returned as an *api.PageError.
+func Build(ctx context.Context, pages []api.ExportPage, options api.BuildOptions, fileName string) (api.BuildReport, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	var report api.BuildReport
+	var err error
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".pdf":
+		report, err = internal.NewPdfBuilder().Build(ctx, pages, options, fileName)
+	case ".cbz":
+		report, err = internal.NewCbzBuilder().Build(ctx, pages, options, fileName)
+	case ".epub":
+		report, err = internal.NewEpubBuilder().Build(ctx, pages, options, fileName)
+	default:
+		return report, fmt.Errorf("file name must end with 'pdf', 'cbz' or 'epub'")
+	}
+
+	for _, d := range report.Dropped {
+		logger.Info("Dropped page from export", "file_name", d.Filename, "page", d.Page, "rule", d.Rule, "reason", d.Reason)
 	}
+	if err != nil {
+		return report, err
+	}
+	report.Files = []string{fileName}
+	return report, nil
+}
 
-	builder := internal.NewPdfBuilder()
+// ExportSources returns the source issues recorded in a PDF exported by Build, so that an export can be
+// matched back to the progs it came from.
+func ExportSources(fileName string) ([]api.ExportSource, error) {
+	return internal.ReadSources(fileName)
+}
 
-	return builder.Build(pages, artistsEdition, fileName)
+// Preview lists the pages that Build would export from the pages passed to it, along with those the page
+// filters and page overrides would leave out, each with a small thumbnail. Cancelling the context stops it.
+func Preview(ctx context.Context, pages []api.ExportPage, options api.BuildOptions) ([]api.PreviewPage, error) {
+	return internal.Preview(ctx, pages, options)
 }
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/chooban/progger/scan/api"
+)
+
+// acbf is an Advanced Comic Book Format description of a CBZ, which readers use for guided view: stepping
+// through the frames, or panels, of each page. See https://acbf.fandom.com/wiki/ACBF_Specifications
+type acbf struct {
+	XMLName  xml.Name     `xml:"ACBF"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	BookInfo acbfBookInfo `xml:"meta-data>book-info"`
+	Publish  acbfPublish  `xml:"meta-data>publish-info"`
+	Pages    []acbfPage   `xml:"body>page"`
+}
+
+type acbfBookInfo struct {
+	Authors   []acbfAuthor  `xml:"author"`
+	Title     string        `xml:"book-title"`
+	Sequence  *acbfSequence `xml:"sequence,omitempty"`
+	Languages []acbfLayer   `xml:"languages>text-layer"`
+	// CoverPage is the first page of the export, which ACBF keeps apart from the rest
+	CoverPage *acbfPage `xml:"coverpage"`
+}
+
+type acbfAuthor struct {
+	Activity  string `xml:"activity,attr"`
+	FirstName string `xml:"first-name,omitempty"`
+	LastName  string `xml:"last-name,omitempty"`
+	Nickname  string `xml:"nickname,omitempty"`
+}
+
+type acbfSequence struct {
+	Title  string `xml:"title,attr"`
+	Number string `xml:",chardata"`
+}
+
+type acbfLayer struct {
+	Lang string `xml:"lang,attr"`
+	Show bool   `xml:"show,attr"`
+}
+
+type acbfPublish struct {
+	Publisher string `xml:"publisher"`
+}
+
+type acbfPage struct {
+	Image  acbfImage   `xml:"image"`
+	Frames []acbfFrame `xml:"frame"`
+}
+
+type acbfImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type acbfFrame struct {
+	Points string `xml:"points,attr"`
+}
+
+// acbfActivities are the ACBF names for the roles credited in an export
+var acbfActivities = []struct {
+	role     api.Role
+	activity string
+}{
+	{api.Script, "Writer"},
+	{api.Art, "Artist"},
+	{api.Colours, "Colorist"},
+	{api.Letters, "Letterer"},
+}
+
+func newAcbf(pages []api.ExportPage) *acbf {
+	m := newExportMetadata(pages)
+	a := &acbf{
+		Xmlns: "http://www.acbf.info/xml/acbf/1.1",
+		BookInfo: acbfBookInfo{
+			Authors:   make([]acbfAuthor, 0),
+			Title:     m.Title(),
+			Languages: []acbfLayer{{Lang: "en", Show: false}},
+		},
+		Publish: acbfPublish{Publisher: "Rebellion"},
+		Pages:   make([]acbfPage, 0),
+	}
+	if series := m.SeriesTitle(); series != "" {
+		a.BookInfo.Sequence = &acbfSequence{Title: series, Number: m.IssueRange()}
+	}
+	for _, credit := range acbfActivities {
+		for _, name := range m.Creators(credit.role) {
+			a.BookInfo.Authors = append(a.BookInfo.Authors, newAcbfAuthor(credit.activity, name))
+		}
+	}
+	return a
+}
+
+// newAcbfAuthor splits a creator's name into the first and last names ACBF asks for. A single name is
+// given as a nickname.
+func newAcbfAuthor(activity, name string) acbfAuthor {
+	name = strings.TrimSpace(name)
+	i := strings.LastIndex(name, " ")
+	if i < 0 {
+		return acbfAuthor{Activity: activity, Nickname: name}
+	}
+	return acbfAuthor{Activity: activity, FirstName: name[:i], LastName: name[i+1:]}
+}
+
+// addPage records the next image in the archive, with the frames found on it
+func (a *acbf) addPage(href string, panels []image.Rectangle) {
+	page := acbfPage{
+		Image:  acbfImage{Href: href},
+		Frames: make([]acbfFrame, 0, len(panels)),
+	}
+	for _, p := range panels {
+		page.Frames = append(page.Frames, acbfFrame{Points: fmt.Sprintf(
+			"%d,%d %d,%d %d,%d %d,%d",
+			p.Min.X, p.Min.Y, p.Max.X, p.Min.Y, p.Max.X, p.Max.Y, p.Min.X, p.Max.Y,
+		)})
+	}
+	if a.BookInfo.CoverPage == nil {
+		a.BookInfo.CoverPage = &page
+		return
+	}
+	a.Pages = append(a.Pages, page)
+}
+
+// frames returns the number of frames recorded across every page
+func (a *acbf) frames() int {
+	count := 0
+	if a.BookInfo.CoverPage != nil {
+		count = len(a.BookInfo.CoverPage.Frames)
+	}
+	for _, p := range a.Pages {
+		count += len(p.Frames)
+	}
+	return count
+}
+
+func (a *acbf) marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
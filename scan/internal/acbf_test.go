@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"encoding/xml"
+	"image"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcbf_Marshal(t *testing.T) {
+	t.Parallel()
+	guide := newAcbf([]api.ExportPage{{
+		Series:      "Brink",
+		Story:       "Hate Box",
+		IssueNumber: 2300,
+		Credits:     api.Credits{api.Script: {"Dan Abnett"}, api.Art: {"INJ Culbard"}, api.Letters: {"Simon Bowland"}},
+	}})
+	guide.addPage("0001.jpg", []image.Rectangle{image.Rect(10, 20, 110, 220)})
+	guide.addPage("0002.jpg", []image.Rectangle{image.Rect(0, 0, 50, 50), image.Rect(60, 0, 100, 50)})
+	guide.addPage("0003.jpg", []image.Rectangle{})
+
+	data, err := guide.marshal()
+	assert.NoError(t, err)
+
+	var decoded acbf
+	assert.NoError(t, xml.Unmarshal(data, &decoded))
+	assert.Equal(t, "Hate Box", decoded.BookInfo.Title)
+	assert.Equal(t, &acbfSequence{Title: "Brink", Number: "2300"}, decoded.BookInfo.Sequence)
+	assert.Equal(t, []acbfAuthor{
+		{Activity: "Writer", FirstName: "Dan", LastName: "Abnett"},
+		{Activity: "Artist", FirstName: "INJ", LastName: "Culbard"},
+		{Activity: "Letterer", FirstName: "Simon", LastName: "Bowland"},
+	}, decoded.BookInfo.Authors)
+	assert.Equal(t, &acbfPage{
+		Image:  acbfImage{Href: "0001.jpg"},
+		Frames: []acbfFrame{{Points: "10,20 110,20 110,220 10,220"}},
+	}, decoded.BookInfo.CoverPage)
+	assert.Equal(t, []acbfPage{
+		{Image: acbfImage{Href: "0002.jpg"}, Frames: []acbfFrame{{Points: "0,0 50,0 50,50 0,50"}, {Points: "60,0 100,0 100,50 60,50"}}},
+		{Image: acbfImage{Href: "0003.jpg"}},
+	}, decoded.Pages)
+	assert.Equal(t, 3, guide.frames())
+}
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"os"
+
+	pdfApi "github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ReadBookmarks returns the outline of a PDF, as read by pdfcpu
+func ReadBookmarks(filename string) ([]pdfcpu.Bookmark, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return pdfApi.Bookmarks(f, nil)
+}
+
+// ReplaceBookmarks overwrites the outline of a PDF in place
+func ReplaceBookmarks(filename string, bookmarks []pdfcpu.Bookmark) error {
+	return pdfApi.AddBookmarksFile(filename, filename, bookmarks, true, nil)
+}
+
+// PageCount returns the number of pages in a PDF
+func PageCount(filename string) (int, error) {
+	return pdfApi.PageCountFile(filename)
+}
@@ -14,6 +14,18 @@ import (
 	"strings"
 )
 
+var (
+	emptyBrackets         = regexp.MustCompile(`[(\[]\s*[)\]]`)
+	reprintMarker         = regexp.MustCompile(`(?i)\s*\b(classics?|reprint(ed)?|rerun)\b`)
+	originalIssuePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(originally|first)\s+(published|printed|appeared|seen)\s+in\s+(2000\s*a\.?d\.?\s*)?prog(ramme)?s?\s*#?\s*(?P<issue>\d{1,4})`),
+		regexp.MustCompile(`(?i)reprinted\s+from\s+(2000\s*a\.?d\.?\s*)?prog(ramme)?s?\s*#?\s*(?P<issue>\d{1,4})`),
+	}
+	// bookmarkOriginalIssue is a bare "(from Prog 245)" added to a reprint's bookmark. It isn't looked for in
+	// page text, where captions such as "continued from prog 2300" are common.
+	bookmarkOriginalIssue = regexp.MustCompile(`(?i)[(\[]\s*from\s+prog(ramme)?\s*#?\s*(?P<issue>\d{1,4})\s*[)\]]`)
+)
+
 func getProgNumber(inFile string) (int, error) {
 	filename := filepath.Base(inFile)
 
@@ -42,7 +54,11 @@ func BuildIssue(log logr.Logger, filename string, details []EpisodeDetails, know
 	for _, d := range details {
 		b := d.Bookmark
 		log.V(2).Info(fmt.Sprintf("Extracting details from %s", b.Title))
-		part, series, title := extractDetailsFromPdfBookmark(b.Title)
+		bookmarkTitle, reprint, originalIssue := detectReprint(b.Title, d.FirstPageText)
+		if reprint {
+			log.V(1).Info("Found a reprint", "bookmark", b.Title, "original_issue", originalIssue)
+		}
+		part, series, title := extractDetailsFromPdfBookmark(bookmarkTitle)
 
 		if series == "" {
 			log.V(1).Info(fmt.Sprintf("Odd title: %s", b.Title))
@@ -69,12 +85,14 @@ func BuildIssue(log logr.Logger, filename string, details []EpisodeDetails, know
 			credits := ExtractCreatorsFromCredits(d.Credits)
 
 			allEpisodes = append(allEpisodes, &api.Episode{
-				Title:     title,
-				Series:    series,
-				Part:      part,
-				FirstPage: b.PageFrom,
-				LastPage:  b.PageThru,
-				Credits:   credits,
+				Title:         title,
+				Series:        series,
+				Part:          part,
+				FirstPage:     b.PageFrom,
+				LastPage:      b.PageThru,
+				Credits:       credits,
+				Reprint:       reprint,
+				OriginalIssue: originalIssue,
 			})
 		} else {
 			log.V(1).Info(fmt.Sprintf("Skipping. Series: %s. Episode: %s", series, title))
@@ -90,6 +108,42 @@ func BuildIssue(log logr.Logger, filename string, details []EpisodeDetails, know
 	return issue
 }
 
+// detectReprint looks for signs that an episode has been published before. Markers such as "Classic" and
+// statements like "from Prog 245" are removed from the bookmark title so that the series and story come out
+// the same as for the original. Page text is only checked for an explicit statement of where the episode
+// first appeared, as words like "classic" turn up in dialogue and "from prog" in recap captions.
+func detectReprint(bookmarkTitle string, pageText string) (title string, reprint bool, originalIssue int) {
+	title = bookmarkTitle
+	for _, regex := range originalIssuePatterns {
+		for _, text := range []string{title, pageText} {
+			if originalIssue != 0 {
+				break
+			}
+			namedResults := FindNamedMatches(regex, text)
+			if issue, err := strconv.Atoi(namedResults["issue"]); err == nil {
+				originalIssue = issue
+				reprint = true
+			}
+		}
+		title = regex.ReplaceAllString(title, "")
+	}
+	if originalIssue == 0 {
+		if issue, err := strconv.Atoi(FindNamedMatches(bookmarkOriginalIssue, title)["issue"]); err == nil {
+			originalIssue = issue
+			reprint = true
+		}
+	}
+	title = bookmarkOriginalIssue.ReplaceAllString(title, "")
+	title = emptyBrackets.ReplaceAllString(title, "")
+
+	if reprintMarker.MatchString(title) {
+		reprint = true
+		title = reprintMarker.ReplaceAllString(title, "")
+	}
+
+	return strings.TrimSpace(title), reprint, originalIssue
+}
+
 func extractDetailsFromPdfBookmark(bookmarkTitle string) (episodeNumber int, series string, storyline string) {
 	// We don't want any zero parts. It's 1 if not specified
 	episodeNumber = -1
@@ -606,3 +606,92 @@ func TestGetProgNumber(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectReprint(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name                  string
+		bookmark              string
+		pageText              string
+		expectedTitle         string
+		expectedReprint       bool
+		expectedOriginalIssue int
+	}{
+		{
+			name:            "Not a reprint",
+			bookmark:        "Judge Dredd: Get Sin - Part 2",
+			pageText:        "A classic Dredd line",
+			expectedTitle:   "Judge Dredd: Get Sin - Part 2",
+			expectedReprint: false,
+		},
+		{
+			name:            "Classic in the series name",
+			bookmark:        "Judge Dredd Classic: The Cursed Earth - Part 3",
+			expectedTitle:   "Judge Dredd: The Cursed Earth - Part 3",
+			expectedReprint: true,
+		},
+		{
+			name:                  "Original issue in the bookmark",
+			bookmark:              "Tharg's Future Shocks: The Reversible Man (from Prog 245)",
+			expectedTitle:         "Tharg's Future Shocks: The Reversible Man",
+			expectedReprint:       true,
+			expectedOriginalIssue: 245,
+		},
+		{
+			name:                  "Original issue in the page text",
+			bookmark:              "Tharg's Future Shocks: The Reversible Man",
+			pageText:              "THIS STORY WAS ORIGINALLY PUBLISHED IN 2000 AD PROG 245",
+			expectedTitle:         "Tharg's Future Shocks: The Reversible Man",
+			expectedReprint:       true,
+			expectedOriginalIssue: 245,
+		},
+		{
+			name:                  "Reprinted from",
+			bookmark:              "Strontium Dog Reprint",
+			pageText:              "Reprinted from Prog #104",
+			expectedTitle:         "Strontium Dog",
+			expectedReprint:       true,
+			expectedOriginalIssue: 104,
+		},
+		{
+			name:            "Continued from an earlier prog",
+			bookmark:        "Judge Dredd: Get Sin - Part 2",
+			pageText:        "CONTINUED FROM PROG 2300",
+			expectedTitle:   "Judge Dredd: Get Sin - Part 2",
+			expectedReprint: false,
+		},
+		{
+			name:            "Continued from an earlier prog in the bookmark",
+			bookmark:        "Judge Dredd: Get Sin - Part 2, continued from prog 2300",
+			expectedTitle:   "Judge Dredd: Get Sin - Part 2, continued from prog 2300",
+			expectedReprint: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			title, reprint, originalIssue := detectReprint(tc.bookmark, tc.pageText)
+			assert.Equal(t, tc.expectedTitle, title)
+			assert.Equal(t, tc.expectedReprint, reprint)
+			assert.Equal(t, tc.expectedOriginalIssue, originalIssue)
+		})
+	}
+}
+
+func TestBuildIssueReprints(t *testing.T) {
+	t.Parallel()
+	details := []EpisodeDetails{
+		{Bookmark: PdfBookmark{Title: "Judge Dredd: Get Sin - Part 2", PageFrom: 3, PageThru: 8}},
+		{Bookmark: PdfBookmark{Title: "Judge Dredd Classic: The Cursed Earth - Part 3", PageFrom: 9, PageThru: 14}},
+	}
+
+	issue := BuildIssue(logr.Discard(), "2000AD 2300 (1977).pdf", details, []string{}, []string{})
+
+	assert.Len(t, issue.Episodes, 2)
+	assert.False(t, issue.Episodes[0].Reprint)
+	assert.True(t, issue.Episodes[1].Reprint)
+	assert.Equal(t, "Judge Dredd", issue.Episodes[1].Series)
+	assert.Equal(t, "The Cursed Earth", issue.Episodes[1].Title)
+	assert.Equal(t, 3, issue.Episodes[1].Part)
+}
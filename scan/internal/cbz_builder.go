@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
+)
+
+// CbzBuilder exports pages as a CBZ: a zip of page images, in reading order, with a ComicInfo.xml
+// describing the contents. When panels are found for guided view, an ACBF file alongside records them.
+type CbzBuilder struct {
+	images *ImageExtractor
+}
+
+func NewCbzBuilder() *CbzBuilder {
+	return &CbzBuilder{
+		images: NewImageExtractor(),
+	}
+}
+
+func (c *CbzBuilder) Build(ctx context.Context, episodes []api.ExportPage, options api.BuildOptions, outputPath string) (report api.BuildReport, buildError error) {
+	progress := newBuildProgress(ctx, options, len(episodes))
+	filter, err := newBuildFilter(logr.FromContextOrDiscard(ctx), options)
+	if err != nil {
+		return report, err
+	}
+	report.Dropped = make([]api.DroppedPage, 0)
+	c.images.profile = options.Profile
+	c.images.spreads = options.Spreads
+	c.images.scroll = options.Scroll
+	c.images.panels = options.Panels
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		if buildError != nil {
+			os.Remove(outputPath)
+		}
+	}()
+
+	archive := zip.NewWriter(f)
+	info := newComicInfo(episodes)
+	var guide *acbf
+	if options.Panels && !options.Scroll.Enabled {
+		guide = newAcbf(episodes)
+	}
+
+	for _, episode := range episodes {
+		if err := progress.startEpisode(episode); err != nil {
+			f.Close()
+			return report, err
+		}
+		images, dropped, err := c.images.Images(episode, options.ArtistsEdition, filter, progress)
+		if err != nil {
+			f.Close()
+			return report, err
+		}
+		report.Dropped = append(report.Dropped, dropped...)
+		for i, img := range images {
+			bookmark := ""
+			if i == 0 {
+				bookmark = episode.Title
+			}
+			name := fmt.Sprintf("%04d.%s", len(info.Pages)+1, img.Ext)
+			if err := writeStored(archive, name, img.Data); err != nil {
+				f.Close()
+				return report, err
+			}
+			info.addPage(img, bookmark)
+			if guide != nil {
+				guide.addPage(name, img.Panels)
+			}
+		}
+		progress.finishEpisode()
+	}
+
+	if err := progress.finishing(); err != nil {
+		f.Close()
+		return report, err
+	}
+	if err := writeComicInfo(archive, info); err != nil {
+		f.Close()
+		return report, err
+	}
+	if guide != nil {
+		name := strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath)) + ".acbf"
+		if err := writeAcbf(archive, name, guide); err != nil {
+			f.Close()
+			return report, err
+		}
+		report.Panels = guide.frames()
+	}
+	report.Pages = len(info.Pages)
+	report.Savings = c.images.savings
+	report.Spreads = c.images.joined
+	if err := archive.Close(); err != nil {
+		f.Close()
+		return report, err
+	}
+	return report, f.Close()
+}
+
+// writeStored adds a file to the archive without compressing it, as suits images that are already
+// compressed, or entries such as an EPUB's mimetype that must not be.
+func writeStored(archive *zip.Writer, name string, data []byte) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{
+		Name:   name,
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func writeDeflated(archive *zip.Writer, name string, data []byte) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func writeComicInfo(archive *zip.Writer, info *comicInfo) error {
+	data, err := info.marshal()
+	if err != nil {
+		return err
+	}
+	return writeDeflated(archive, "ComicInfo.xml", data)
+}
+
+func writeAcbf(archive *zip.Writer, name string, guide *acbf) error {
+	data, err := guide.marshal()
+	if err != nil {
+		return err
+	}
+	return writeDeflated(archive, name, data)
+}
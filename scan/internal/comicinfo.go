@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/chooban/progger/scan/api"
+)
+
+// comicInfo is the ComicInfo.xml read by comic reader apps from CBZ files. See
+// https://anansi-project.github.io/docs/comicinfo/schemas/v2.0
+type comicInfo struct {
+	XMLName     xml.Name        `xml:"ComicInfo"`
+	XmlnsXsi    string          `xml:"xmlns:xsi,attr"`
+	XmlnsXsd    string          `xml:"xmlns:xsd,attr"`
+	Title       string          `xml:"Title,omitempty"`
+	Series      string          `xml:"Series,omitempty"`
+	Number      string          `xml:"Number,omitempty"`
+	Summary     string          `xml:"Summary,omitempty"`
+	Writer      string          `xml:"Writer,omitempty"`
+	Penciller   string          `xml:"Penciller,omitempty"`
+	Colorist    string          `xml:"Colorist,omitempty"`
+	Letterer    string          `xml:"Letterer,omitempty"`
+	Publisher   string          `xml:"Publisher,omitempty"`
+	Imprint     string          `xml:"Imprint,omitempty"`
+	PageCount   int             `xml:"PageCount"`
+	LanguageISO string          `xml:"LanguageISO,omitempty"`
+	Manga       string          `xml:"Manga,omitempty"`
+	Pages       []comicPageInfo `xml:"Pages>Page"`
+}
+
+type comicPageInfo struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	DoublePage  bool   `xml:"DoublePage,attr,omitempty"`
+	ImageSize   int    `xml:"ImageSize,attr,omitempty"`
+	ImageWidth  int    `xml:"ImageWidth,attr,omitempty"`
+	ImageHeight int    `xml:"ImageHeight,attr,omitempty"`
+	Bookmark    string `xml:"Bookmark,attr,omitempty"`
+}
+
+func newComicInfo(pages []api.ExportPage) *comicInfo {
+	m := newExportMetadata(pages)
+	return &comicInfo{
+		XmlnsXsi:    "http://www.w3.org/2001/XMLSchema-instance",
+		XmlnsXsd:    "http://www.w3.org/2001/XMLSchema",
+		Title:       m.Title(),
+		Series:      m.SeriesTitle(),
+		Number:      m.IssueRange(),
+		Summary:     m.Summary(),
+		Writer:      strings.Join(m.Creators(api.Script), ", "),
+		Penciller:   strings.Join(m.Creators(api.Art), ", "),
+		Colorist:    strings.Join(m.Creators(api.Colours), ", "),
+		Letterer:    strings.Join(m.Creators(api.Letters), ", "),
+		Publisher:   "Rebellion",
+		Imprint:     strings.Join(m.Publications, ", "),
+		LanguageISO: "en",
+		Manga:       "No",
+		Pages:       make([]comicPageInfo, 0),
+	}
+}
+
+// addPage records the next image in the archive, bookmarking it if it starts an episode
+func (c *comicInfo) addPage(img PageImage, bookmark string) {
+	page := comicPageInfo{
+		Image:       len(c.Pages),
+		Type:        "Story",
+		DoublePage:  img.Spread,
+		ImageSize:   len(img.Data),
+		ImageWidth:  img.Width,
+		ImageHeight: img.Height,
+		Bookmark:    bookmark,
+	}
+	c.Pages = append(c.Pages, page)
+	c.PageCount = len(c.Pages)
+}
+
+func (c *comicInfo) marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
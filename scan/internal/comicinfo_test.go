@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewComicInfo(t *testing.T) {
+	t.Parallel()
+	pages := []api.ExportPage{
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2301,
+			Series:      "Judge Dredd",
+			Story:       "Get Sin",
+			Part:        1,
+			Credits:     api.Credits{api.Script: {"John Wagner"}, api.Art: {"Dan Cornwell"}},
+		},
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2302,
+			Series:      "Judge Dredd",
+			Story:       "Get Sin",
+			Part:        2,
+			Credits:     api.Credits{api.Script: {"John Wagner"}, api.Art: {"Dan Cornwell"}, api.Letters: {"Annie Parkhouse"}},
+		},
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2305,
+			Series:      "Judge Dredd",
+			Story:       "Get Sin",
+			Part:        3,
+			Credits:     api.Credits{api.Script: {"John Wagner"}, api.Art: {"Colin MacNeil"}},
+		},
+	}
+
+	info := newComicInfo(pages)
+
+	assert.Equal(t, "Judge Dredd", info.Series)
+	assert.Equal(t, "Get Sin", info.Title)
+	assert.Equal(t, "2301-2302, 2305", info.Number)
+	assert.Equal(t, "John Wagner", info.Writer)
+	assert.Equal(t, "Dan Cornwell, Colin MacNeil", info.Penciller)
+	assert.Equal(t, "Annie Parkhouse", info.Letterer)
+	assert.Equal(t, "2000 AD", info.Imprint)
+	assert.Equal(t, "Judge Dredd: Get Sin, from 2000 AD Progs 2301-2302, 2305", info.Summary)
+}
+
+func TestComicInfo_Marshal(t *testing.T) {
+	t.Parallel()
+	info := newComicInfo([]api.ExportPage{{Series: "Brink", Story: "Hate Box", IssueNumber: 2300}})
+	info.addPage(PageImage{Data: make([]byte, 10), Width: 100, Height: 150}, "Hate Box - Part 1")
+	info.addPage(PageImage{Data: make([]byte, 12), Width: 100, Height: 150}, "")
+	info.addPage(PageImage{Data: make([]byte, 20), Width: 200, Height: 150, Spread: true}, "")
+
+	data, err := info.marshal()
+	assert.NoError(t, err)
+
+	var decoded comicInfo
+	assert.NoError(t, xml.Unmarshal(data, &decoded))
+	assert.Equal(t, 3, decoded.PageCount)
+	assert.Equal(t, []comicPageInfo{
+		{Image: 0, Type: "Story", ImageSize: 10, ImageWidth: 100, ImageHeight: 150, Bookmark: "Hate Box - Part 1"},
+		{Image: 1, Type: "Story", ImageSize: 12, ImageWidth: 100, ImageHeight: 150},
+		{Image: 2, Type: "Story", DoublePage: true, ImageSize: 20, ImageWidth: 200, ImageHeight: 150},
+	}, decoded.Pages)
+}
+
+func TestIssueRange(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		issues   []int
+		expected string
+	}{
+		{issues: []int{}, expected: ""},
+		{issues: []int{2300}, expected: "2300"},
+		{issues: []int{2302, 2300, 2301}, expected: "2300-2302"},
+		{issues: []int{1, 3, 4, 4, 7}, expected: "1, 3-4, 7"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, issueRange(tc.issues))
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
+)
+
+// EpubBuilder exports pages as a fixed-layout EPUB3, with one page image per spine item. This suits
+// e-readers that have poor support for PDFs.
+type EpubBuilder struct {
+	images *ImageExtractor
+}
+
+func NewEpubBuilder() *EpubBuilder {
+	return &EpubBuilder{
+		images: NewImageExtractor(),
+	}
+}
+
+type epubPage struct {
+	Id      string
+	Image   string
+	Page    string
+	Width   int
+	Height  int
+	Chapter string
+}
+
+type epubCreator struct {
+	Name  string
+	Roles []string
+}
+
+type epubFile struct {
+	name     string
+	template *template.Template
+	data     any
+}
+
+type epub struct {
+	Identifier  string
+	Title       string
+	Series      string
+	Source      string
+	Description string
+	Publisher   string
+	Modified    string
+	Creators    []epubCreator
+	Pages       []epubPage
+}
+
+func (e *EpubBuilder) Build(ctx context.Context, episodes []api.ExportPage, options api.BuildOptions, outputPath string) (report api.BuildReport, buildError error) {
+	progress := newBuildProgress(ctx, options, len(episodes))
+	filter, err := newBuildFilter(logr.FromContextOrDiscard(ctx), options)
+	if err != nil {
+		return report, err
+	}
+	report.Dropped = make([]api.DroppedPage, 0)
+	e.images.profile = options.Profile
+	e.images.spreads = options.Spreads
+	e.images.scroll = options.Scroll
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		if buildError != nil {
+			os.Remove(outputPath)
+		}
+	}()
+
+	archive := zip.NewWriter(f)
+	// The mimetype must be the first entry and must not be compressed
+	if err := writeStored(archive, "mimetype", []byte("application/epub+zip")); err != nil {
+		f.Close()
+		return report, err
+	}
+
+	book := newEpub(episodes)
+	for _, episode := range episodes {
+		if err := progress.startEpisode(episode); err != nil {
+			f.Close()
+			return report, err
+		}
+		images, dropped, err := e.images.Images(episode, options.ArtistsEdition, filter, progress)
+		if err != nil {
+			f.Close()
+			return report, err
+		}
+		report.Dropped = append(report.Dropped, dropped...)
+		for i, img := range images {
+			id := fmt.Sprintf("p%04d", len(book.Pages)+1)
+			page := epubPage{
+				Id:     id,
+				Image:  fmt.Sprintf("images/%s.%s", id, img.Ext),
+				Page:   fmt.Sprintf("pages/%s.xhtml", id),
+				Width:  img.Width,
+				Height: img.Height,
+			}
+			if i == 0 {
+				page.Chapter = episode.Title
+			}
+			if err := writeStored(archive, "OEBPS/"+page.Image, img.Data); err != nil {
+				f.Close()
+				return report, err
+			}
+			book.Pages = append(book.Pages, page)
+		}
+		progress.finishEpisode()
+	}
+	if len(book.Pages) == 0 {
+		f.Close()
+		return report, errors.New("every page was filtered out, leaving nothing to export")
+	}
+
+	if err := progress.finishing(); err != nil {
+		f.Close()
+		return report, err
+	}
+	files := []epubFile{
+		{name: "META-INF/container.xml", template: epubContainer, data: nil},
+		{name: "OEBPS/content.opf", template: epubPackage, data: book},
+		{name: "OEBPS/nav.xhtml", template: epubNav, data: book},
+	}
+	for _, p := range book.Pages {
+		files = append(files, epubFile{name: "OEBPS/" + p.Page, template: epubPageTemplate, data: p})
+	}
+
+	for _, file := range files {
+		var buf bytes.Buffer
+		if err := file.template.Execute(&buf, file.data); err != nil {
+			f.Close()
+			return report, err
+		}
+		if err := writeDeflated(archive, file.name, buf.Bytes()); err != nil {
+			f.Close()
+			return report, err
+		}
+	}
+
+	report.Pages = len(book.Pages)
+	report.Savings = e.images.savings
+	report.Spreads = e.images.joined
+	if err := archive.Close(); err != nil {
+		f.Close()
+		return report, err
+	}
+	return report, f.Close()
+}
+
+func newEpub(pages []api.ExportPage) *epub {
+	m := newExportMetadata(pages)
+
+	// Creators are listed once, with every role they had. The roles are MARC relator codes, which have no code
+	// for a letterer, so they are listed as contributors.
+	relators := []struct {
+		role api.Role
+		code string
+	}{
+		{api.Script, "aut"},
+		{api.Art, "art"},
+		{api.Colours, "clr"},
+		{api.Letters, "ctb"},
+	}
+	creators := make([]epubCreator, 0)
+	for _, r := range relators {
+		for _, name := range m.Creators(r.role) {
+			idx := slices.IndexFunc(creators, func(c epubCreator) bool { return c.Name == name })
+			if idx < 0 {
+				creators = append(creators, epubCreator{Name: name})
+				idx = len(creators) - 1
+			}
+			creators[idx].Roles = append(creators[idx].Roles, r.code)
+		}
+	}
+
+	title := m.Title()
+	if title == "" {
+		title = m.SeriesTitle()
+	}
+	source := ""
+	if len(m.Issues) > 0 {
+		source = fmt.Sprintf("%s %s", strings.Join(m.Publications, ", "), m.IssueRange())
+	}
+
+	return &epub{
+		Identifier:  epubIdentifier(pages),
+		Title:       title,
+		Series:      m.SeriesTitle(),
+		Source:      strings.TrimSpace(source),
+		Description: m.Summary(),
+		Publisher:   "Rebellion",
+		Modified:    time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Creators:    creators,
+		Pages:       make([]epubPage, 0),
+	}
+}
+
+// epubIdentifier derives a UUID from the exported pages, so that re-exporting the same story gives a book
+// that readers recognise as the same one.
+func epubIdentifier(pages []api.ExportPage) string {
+	h := sha1.New()
+	for _, p := range pages {
+		fmt.Fprintf(h, "%s|%d|%s|%d|%d\n", p.Publication, p.IssueNumber, p.Title, p.PageFrom, p.PageTo)
+	}
+	sum := h.Sum(nil)
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+var epubFuncs = template.FuncMap{"x": escapeXml}
+
+var epubContainer = template.Must(template.New("container").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`))
+
+var epubPackage = template.Must(template.New("package").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid" prefix="rendition: http://www.idpf.org/vocab/rendition/#">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">{{ .Identifier }}</dc:identifier>
+    <dc:title>{{ x .Title }}</dc:title>
+    <dc:language>en</dc:language>
+    <dc:publisher>{{ x .Publisher }}</dc:publisher>
+    {{- if .Description }}
+    <dc:description>{{ x .Description }}</dc:description>
+    {{- end }}
+    {{- if .Source }}
+    <dc:source>{{ x .Source }}</dc:source>
+    {{- end }}
+    {{- range $i, $c := .Creators }}
+    <dc:creator id="creator{{ $i }}">{{ x $c.Name }}</dc:creator>
+    {{- range $c.Roles }}
+    <meta refines="#creator{{ $i }}" property="role" scheme="marc:relators">{{ . }}</meta>
+    {{- end }}
+    {{- end }}
+    {{- if .Series }}
+    <meta property="belongs-to-collection" id="series">{{ x .Series }}</meta>
+    <meta refines="#series" property="collection-type">series</meta>
+    {{- end }}
+    <meta property="dcterms:modified">{{ .Modified }}</meta>
+    <meta property="rendition:layout">pre-paginated</meta>
+    <meta property="rendition:orientation">auto</meta>
+    <meta property="rendition:spread">none</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    {{- range $i, $p := .Pages }}
+    <item id="{{ $p.Id }}-image" href="{{ $p.Image }}" media-type="image/jpeg"{{ if eq $i 0 }} properties="cover-image"{{ end }}/>
+    <item id="{{ $p.Id }}" href="{{ $p.Page }}" media-type="application/xhtml+xml"/>
+    {{- end }}
+  </manifest>
+  <spine>
+    {{- range .Pages }}
+    <itemref idref="{{ .Id }}"/>
+    {{- end }}
+  </spine>
+</package>
+`))
+
+var epubNav = template.Must(template.New("nav").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <title>{{ x .Title }}</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <ol>
+      {{- range .Pages }}
+      {{- if .Chapter }}
+      <li><a href="{{ .Page }}">{{ x .Chapter }}</a></li>
+      {{- end }}
+      {{- end }}
+    </ol>
+  </nav>
+</body>
+</html>
+`))
+
+var epubPageTemplate = template.Must(template.New("page").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+  <title>{{ .Id }}</title>
+  <meta name="viewport" content="width={{ .Width }}, height={{ .Height }}"/>
+  <style>html, body { margin: 0; padding: 0; } img { display: block; width: 100%; height: 100%; }</style>
+</head>
+<body>
+  <img src="../{{ .Image }}" alt=""/>
+</body>
+</html>
+`))
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"path/filepath"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func epubTestPages() []api.ExportPage {
+	return []api.ExportPage{
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2301,
+			Title:       "Get Sin & Sorrow - Part 1",
+			Series:      "Judge Dredd",
+			Story:       "Get Sin & Sorrow",
+			Credits:     api.Credits{api.Script: {"John Wagner"}, api.Art: {"John Wagner", "Dan Cornwell"}, api.Letters: {"Annie Parkhouse"}},
+			PageFrom:    3,
+			PageTo:      8,
+		},
+	}
+}
+
+func TestNewEpub(t *testing.T) {
+	t.Parallel()
+	book := newEpub(epubTestPages())
+
+	assert.Equal(t, "Get Sin & Sorrow", book.Title)
+	assert.Equal(t, "Judge Dredd", book.Series)
+	assert.Equal(t, "2000 AD 2301", book.Source)
+	assert.Equal(t, []epubCreator{
+		{Name: "John Wagner", Roles: []string{"aut", "art"}},
+		{Name: "Dan Cornwell", Roles: []string{"art"}},
+		{Name: "Annie Parkhouse", Roles: []string{"ctb"}},
+	}, book.Creators)
+	assert.Equal(t, book.Identifier, newEpub(epubTestPages()).Identifier)
+	assert.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, book.Identifier)
+}
+
+func TestEpubTemplates(t *testing.T) {
+	t.Parallel()
+	book := newEpub(epubTestPages())
+	book.Pages = append(book.Pages,
+		epubPage{Id: "p0001", Image: "images/p0001.jpg", Page: "pages/p0001.xhtml", Width: 10, Height: 15, Chapter: "Get Sin & Sorrow - Part 1"},
+		epubPage{Id: "p0002", Image: "images/p0002.jpg", Page: "pages/p0002.xhtml", Width: 10, Height: 15},
+	)
+
+	var opf bytes.Buffer
+	assert.NoError(t, epubPackage.Execute(&opf, book))
+	var pkg struct {
+		Title string `xml:"metadata>title"`
+		Spine []struct {
+			IdRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+		Items []struct {
+			Id         string `xml:"id,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"manifest>item"`
+	}
+	assert.NoError(t, xml.Unmarshal(opf.Bytes(), &pkg))
+	assert.Equal(t, "Get Sin & Sorrow", pkg.Title)
+	assert.Len(t, pkg.Items, 5)
+	assert.Equal(t, "cover-image", pkg.Items[1].Properties)
+	assert.Len(t, pkg.Spine, 2)
+
+	var nav bytes.Buffer
+	assert.NoError(t, epubNav.Execute(&nav, book))
+	var toc struct {
+		Links []string `xml:"body>nav>ol>li>a"`
+	}
+	assert.NoError(t, xml.Unmarshal(nav.Bytes(), &toc))
+	assert.Equal(t, []string{"Get Sin & Sorrow - Part 1"}, toc.Links)
+}
+
+func TestEpubBuilderNothingToExport(t *testing.T) {
+	t.Parallel()
+	output := filepath.Join(t.TempDir(), "empty.epub")
+
+	_, err := NewEpubBuilder().Build(context.Background(), nil, api.BuildOptions{}, output)
+
+	assert.Error(t, err)
+	assert.NoFileExists(t, output)
+}
@@ -0,0 +1,321 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	_ "image/png"
+	"math/bits"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+const (
+	// defaultSimilarity is the largest hash distance at which two pages are taken to be the same image
+	defaultSimilarity = 10
+	// hashDPI is the resolution pages are rendered at for comparing with known images
+	hashDPI = 36
+)
+
+// overrideRule names the rule behind pages left out by a page override
+const overrideRule = "Preview"
+
+var (
+	advertText  = regexp.MustCompile(`(?i)on sale now|on sale \d{1,2} \w+ \d{4}|subscribe (now|today)|available (now|in all good)|pre-?order`)
+	lettersText = regexp.MustCompile(`(?i)\b(input|nerve cent(re|er)|letters? page)\b.*\b(write to|e-?mail)\b`)
+)
+
+type compiledRule struct {
+	api.PageFilterRule
+	text   *regexp.Regexp
+	hashes []uint64
+}
+
+// PageFilter applies page filter rules to the pages of an episode
+type PageFilter struct {
+	rules     []compiledRule
+	overrides []api.PageOverride
+	log       logr.Logger
+}
+
+// A candidatePage is a page being considered by the filter. Its text and image hash are only worked out if
+// a rule needs them.
+type candidatePage struct {
+	Page int
+	Text func() string
+	Hash func() (uint64, error)
+}
+
+// NewPageFilter compiles the rules, loading any images they match against
+func NewPageFilter(rules []api.PageFilterRule) (*PageFilter, error) {
+	f := &PageFilter{rules: make([]compiledRule, 0, len(rules)), log: logr.Discard()}
+	for _, r := range rules {
+		c := compiledRule{PageFilterRule: r}
+		if r.Text != "" {
+			re, err := regexp.Compile("(?i)" + r.Text)
+			if err != nil {
+				return nil, fmt.Errorf("page filter %q: %w", r.Name, err)
+			}
+			c.text = re
+		}
+		for _, filename := range r.Images {
+			h, err := hashImageFile(filename)
+			if err != nil {
+				return nil, fmt.Errorf("page filter %q: %w", r.Name, err)
+			}
+			c.hashes = append(c.hashes, h)
+		}
+		if c.Similarity == 0 {
+			c.Similarity = defaultSimilarity
+		}
+		f.rules = append(f.rules, c)
+	}
+	return f, nil
+}
+
+// Pages returns the pages of the range to keep, and those dropped along with why
+func (f *PageFilter) Pages(instance pdfium.Pdfium, document references.FPDF_DOCUMENT, pageFrom, pageTo int) ([]int, []api.DroppedPage) {
+	candidates := make([]candidatePage, 0, pageTo-pageFrom+1)
+	for pageNum := pageFrom; pageNum <= pageTo; pageNum++ {
+		candidates = append(candidates, f.newCandidatePage(instance, document, pageNum))
+	}
+	return f.filter(candidates)
+}
+
+// Episode returns the pages of an episode to keep, and those dropped along with why. Any page overrides are
+// applied over the rules.
+func (f *PageFilter) Episode(instance pdfium.Pdfium, document references.FPDF_DOCUMENT, episode api.ExportPage) ([]int, []api.DroppedPage) {
+	kept, dropped := f.Pages(instance, document, episode.PageFrom, episode.PageTo)
+	for i := range dropped {
+		dropped[i].Filename = episode.Filename
+		dropped[i].IssueNumber = episode.IssueNumber
+	}
+	return f.override(episode, kept, dropped)
+}
+
+// override moves the pages of an episode that have been kept or left out by hand
+func (f *PageFilter) override(episode api.ExportPage, kept []int, dropped []api.DroppedPage) ([]int, []api.DroppedPage) {
+	if len(f.overrides) == 0 {
+		return kept, dropped
+	}
+	keep := func(page int) (keep, found bool) {
+		for _, o := range f.overrides {
+			if o.Filename == episode.Filename && o.Page == page {
+				return o.Keep, true
+			}
+		}
+		return false, false
+	}
+
+	overriddenKept := make([]int, 0, len(kept))
+	overriddenDropped := make([]api.DroppedPage, 0, len(dropped))
+	for _, d := range dropped {
+		if k, found := keep(d.Page); found && k {
+			overriddenKept = append(overriddenKept, d.Page)
+		} else {
+			overriddenDropped = append(overriddenDropped, d)
+		}
+	}
+	for _, page := range kept {
+		if k, found := keep(page); found && !k {
+			overriddenDropped = append(overriddenDropped, api.DroppedPage{
+				Filename:    episode.Filename,
+				IssueNumber: episode.IssueNumber,
+				Page:        page,
+				Rule:        overrideRule,
+				Reason:      "left out in the preview",
+			})
+		} else {
+			overriddenKept = append(overriddenKept, page)
+		}
+	}
+	slices.Sort(overriddenKept)
+	slices.SortFunc(overriddenDropped, func(a, b api.DroppedPage) int { return a.Page - b.Page })
+	return overriddenKept, overriddenDropped
+}
+
+func (f *PageFilter) filter(pages []candidatePage) ([]int, []api.DroppedPage) {
+	drop := make(map[int]api.DroppedPage)
+
+	for _, p := range pages {
+		if rule, reason, ok := f.match(p, false); ok {
+			drop[p.Page] = api.DroppedPage{Page: p.Page, Rule: rule, Reason: reason}
+		}
+	}
+
+	// Trailing rules work backwards from the end, stopping at the first page that is kept. Conceivably,
+	// the phrase "on sale now" might be in the dialogue, so checking every page doesn't make sense.
+	for i := len(pages) - 1; i > 0; i-- {
+		p := pages[i]
+		if _, ok := drop[p.Page]; ok {
+			continue
+		}
+		rule, reason, ok := f.match(p, true)
+		if !ok {
+			break
+		}
+		drop[p.Page] = api.DroppedPage{Page: p.Page, Rule: rule, Reason: reason}
+	}
+
+	kept := make([]int, 0, len(pages))
+	dropped := make([]api.DroppedPage, 0)
+	for _, p := range pages {
+		if d, ok := drop[p.Page]; ok {
+			dropped = append(dropped, d)
+		} else {
+			kept = append(kept, p.Page)
+		}
+	}
+	return kept, dropped
+}
+
+func (f *PageFilter) match(p candidatePage, trailing bool) (rule, reason string, ok bool) {
+	for _, r := range f.rules {
+		if r.TrailingOnly != trailing {
+			continue
+		}
+		if r.text != nil {
+			if m := r.text.FindString(p.Text()); m != "" {
+				return r.Name, fmt.Sprintf("text matched %q", strings.TrimSpace(m)), true
+			}
+		}
+		if r.Classification != api.PageUnclassified {
+			if class := classifyPage(p.Text()); class == r.Classification {
+				return r.Name, fmt.Sprintf("classified as %s", class), true
+			}
+		}
+		if len(r.hashes) > 0 {
+			h, err := p.Hash()
+			if err != nil {
+				f.log.Error(err, "Could not compare page with known images", "page", p.Page)
+				continue
+			}
+			for i, known := range r.hashes {
+				if d := bits.OnesCount64(h ^ known); d <= r.Similarity {
+					return r.Name, fmt.Sprintf("looks like %s (distance %d)", r.Images[i], d), true
+				}
+			}
+		}
+	}
+	return "", "", false
+}
+
+func (f *PageFilter) newCandidatePage(instance pdfium.Pdfium, document references.FPDF_DOCUMENT, pageNum int) candidatePage {
+	page := requests.Page{
+		ByIndex: &requests.PageByIndex{
+			Document: document,
+			Index:    pageNum - 1,
+		},
+	}
+
+	var text *string
+	var hash *uint64
+	return candidatePage{
+		Page: pageNum,
+		Text: func() string {
+			if text == nil {
+				t := ""
+				if r, err := instance.GetPageText(&requests.GetPageText{Page: page}); err != nil {
+					f.log.Error(err, "Could not read text from page", "page", pageNum)
+				} else {
+					t = r.Text
+				}
+				text = &t
+			}
+			return *text
+		},
+		Hash: func() (uint64, error) {
+			if hash == nil {
+				rendered, err := instance.RenderPageInDPI(&requests.RenderPageInDPI{Page: page, DPI: hashDPI})
+				if err != nil {
+					return 0, err
+				}
+				h := imageHash(rendered.Result.Image)
+				if rendered.CleanupFunc != nil {
+					rendered.CleanupFunc()
+				}
+				hash = &h
+			}
+			return *hash, nil
+		},
+	}
+}
+
+// classifyPage makes a rough guess at what a page holds from its text
+func classifyPage(text string) api.PageClass {
+	switch {
+	case strings.TrimSpace(text) == "":
+		return api.PageUnclassified
+	case lettersText.MatchString(strings.Join(strings.Fields(text), " ")):
+		return api.PageLetters
+	case advertText.MatchString(text):
+		return api.PageAdvert
+	}
+	return api.PageStory
+}
+
+func hashImageFile(filename string) (uint64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, fmt.Errorf("decoding %s: %w", filename, err)
+	}
+	return imageHash(img), nil
+}
+
+// imageHash is a difference hash of the image: it is shrunk to 9x8 greys, and each bit records whether a
+// pixel is brighter than its neighbour. Similar images have hashes that differ in only a few bits,
+// regardless of their size or compression.
+func imageHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	bounds := img.Bounds()
+
+	var grey [h][w]uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Average the block of source pixels that this cell covers
+			x0 := bounds.Min.X + x*bounds.Dx()/w
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/w)
+			y0 := bounds.Min.Y + y*bounds.Dy()/h
+			y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/h)
+
+			var sum, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sum += (299*uint64(r) + 587*uint64(g) + 114*uint64(b)) / 1000
+					count++
+				}
+			}
+			grey[y][x] = sum / count
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if grey[y][x] > grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// pageRanges formats page numbers as a pdfium page range, e.g. "3-5,7"
+func pageRanges(pages []int) string {
+	return formatRanges(pages, ",")
+}
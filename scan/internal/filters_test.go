@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func textPages(texts ...string) []candidatePage {
+	pages := make([]candidatePage, 0, len(texts))
+	for i, t := range texts {
+		pages = append(pages, candidatePage{
+			Page: i + 1,
+			Text: func() string { return t },
+			Hash: func() (uint64, error) { return 0, nil },
+		})
+	}
+	return pages
+}
+
+func TestPageFilter_Filter(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name            string
+		rules           []api.PageFilterRule
+		pages           []candidatePage
+		expectedKept    []int
+		expectedDropped []int
+	}{
+		{
+			name:            "Default trailing adverts",
+			rules:           api.DefaultPageFilters(),
+			pages:           textPages("Drokk!", "Stomm!", "ON SALE NOW", "On sale 12 March 2025"),
+			expectedKept:    []int{1, 2},
+			expectedDropped: []int{3, 4},
+		},
+		{
+			name:            "Trailing rules stop at the first kept page",
+			rules:           api.DefaultPageFilters(),
+			pages:           textPages("Drokk!", "The Megazine is on sale now, creep", "Stomm!"),
+			expectedKept:    []int{1, 2, 3},
+			expectedDropped: []int{},
+		},
+		{
+			name:            "Trailing rules never drop the first page",
+			rules:           api.DefaultPageFilters(),
+			pages:           textPages("On sale now", "On sale now"),
+			expectedKept:    []int{1},
+			expectedDropped: []int{2},
+		},
+		{
+			name:            "Rules can drop pages anywhere",
+			rules:           []api.PageFilterRule{{Name: "Letters", Classification: api.PageLetters}},
+			pages:           textPages("Drokk!", "INPUT\nWrite to Tharg", "Stomm!"),
+			expectedKept:    []int{1, 3},
+			expectedDropped: []int{2},
+		},
+		{
+			name:            "An empty rule set keeps everything",
+			rules:           []api.PageFilterRule{},
+			pages:           textPages("Drokk!", "On sale now"),
+			expectedKept:    []int{1, 2},
+			expectedDropped: []int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			f, err := NewPageFilter(tc.rules)
+			assert.NoError(t, err)
+
+			kept, dropped := f.filter(tc.pages)
+			droppedPages := make([]int, 0, len(dropped))
+			for _, d := range dropped {
+				droppedPages = append(droppedPages, d.Page)
+				assert.NotEmpty(t, d.Reason)
+			}
+			assert.Equal(t, tc.expectedKept, kept)
+			assert.Equal(t, tc.expectedDropped, droppedPages)
+		})
+	}
+}
+
+func TestPageFilter_ImageRule(t *testing.T) {
+	t.Parallel()
+	houseAd := imageHash(gradient(90, 120, false))
+	f := &PageFilter{rules: []compiledRule{{
+		PageFilterRule: api.PageFilterRule{Name: "House ads", Images: []string{"house-ad.png"}, Similarity: defaultSimilarity},
+		hashes:         []uint64{houseAd},
+	}}}
+
+	pages := []candidatePage{
+		{Page: 1, Text: func() string { return "" }, Hash: func() (uint64, error) { return imageHash(gradient(180, 240, true)), nil }},
+		{Page: 2, Text: func() string { return "" }, Hash: func() (uint64, error) { return imageHash(gradient(180, 240, false)), nil }},
+	}
+	kept, dropped := f.filter(pages)
+
+	assert.Equal(t, []int{1}, kept)
+	assert.Len(t, dropped, 1)
+	assert.Equal(t, "House ads", dropped[0].Rule)
+}
+
+func TestPageFilter_Override(t *testing.T) {
+	t.Parallel()
+	episode := api.ExportPage{Filename: "2000AD 2300.pdf", IssueNumber: 2300, PageFrom: 3, PageTo: 7}
+	f := &PageFilter{overrides: []api.PageOverride{
+		{Filename: "2000AD 2300.pdf", Page: 4, Keep: false},
+		{Filename: "2000AD 2300.pdf", Page: 7, Keep: true},
+		{Filename: "2000AD 2301.pdf", Page: 5, Keep: false},
+	}}
+	dropped := []api.DroppedPage{
+		{Filename: "2000AD 2300.pdf", IssueNumber: 2300, Page: 6, Rule: "Trailing adverts"},
+		{Filename: "2000AD 2300.pdf", IssueNumber: 2300, Page: 7, Rule: "Trailing adverts"},
+	}
+
+	kept, dropped := f.override(episode, []int{3, 4, 5}, dropped)
+
+	assert.Equal(t, []int{3, 5, 7}, kept)
+	assert.Equal(t, []int{4, 6}, []int{dropped[0].Page, dropped[1].Page})
+	assert.Equal(t, overrideRule, dropped[0].Rule)
+	assert.Equal(t, 2300, dropped[0].IssueNumber)
+	assert.Equal(t, "Trailing adverts", dropped[1].Rule)
+}
+
+func gradient(width, height int, reverse bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(x * 255 / width)
+			if reverse {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestClassifyPage(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		text     string
+		expected api.PageClass
+	}{
+		{text: "", expected: api.PageUnclassified},
+		{text: "STAY WHERE YOU ARE, CREEP!", expected: api.PageStory},
+		{text: "Judge Dredd Megazine 400 on sale now", expected: api.PageAdvert},
+		{text: "INPUT\nTharg's Nerve Centre\nWrite to us at", expected: api.PageLetters},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expected.String(), func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, classifyPage(tc.text))
+		})
+	}
+}
+
+func TestPageRanges(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "3-5,7,9-10", pageRanges([]int{3, 4, 5, 7, 9, 10}))
+	assert.Equal(t, "", pageRanges([]int{}))
+}
@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chooban/progger/scan/api"
+)
+
+const (
+	pageMargin     = 54.0
+	lineSpacing    = 1.5
+	headingSize    = 20.0
+	entrySize      = 11.0
+	creditSize     = 10.0
+	titleSize      = 30.0
+	subtitleSize   = 20.0
+	issueRangeSize = 14.0
+)
+
+type alignment int
+
+const (
+	alignLeft alignment = iota
+	alignCentre
+	alignRight
+)
+
+// A textLine is a single run of text on a generated page. Y is the baseline, measured up from the bottom of
+// the page as PDF coordinates are. X is ignored for centred text, and is the right edge for right aligned.
+type textLine struct {
+	Text  string
+	Size  float64
+	Bold  bool
+	Align alignment
+	X     float64
+	Y     float64
+}
+
+// A generatedPage is a page of text added to an export, such as the title or contents page
+type generatedPage struct {
+	Lines []textLine
+}
+
+// pageLayout flows lines of text down pages of a fixed size, starting a new page when one fills up
+type pageLayout struct {
+	width, height float64
+	heading       string
+	pages         []generatedPage
+	y             float64
+}
+
+func newPageLayout(width, height float64, heading string) *pageLayout {
+	l := &pageLayout{width: width, height: height, heading: heading, pages: make([]generatedPage, 0)}
+	l.newPage()
+	return l
+}
+
+func (l *pageLayout) newPage() {
+	l.pages = append(l.pages, generatedPage{Lines: make([]textLine, 0)})
+	l.y = l.height - pageMargin
+	if l.heading != "" {
+		l.y -= headingSize
+		l.add(textLine{Text: l.heading, Size: headingSize, Bold: true, Align: alignCentre, Y: l.y})
+		l.y -= headingSize * lineSpacing
+	}
+}
+
+func (l *pageLayout) add(line textLine) {
+	page := &l.pages[len(l.pages)-1]
+	page.Lines = append(page.Lines, line)
+}
+
+// reserve moves down the page by the height of the given lines, starting a new page if they won't fit
+func (l *pageLayout) reserve(sizes ...float64) {
+	needed := 0.0
+	for _, s := range sizes {
+		needed += s * lineSpacing
+	}
+	if l.y-needed < pageMargin && len(l.pages[len(l.pages)-1].Lines) > 1 {
+		l.newPage()
+	}
+}
+
+func (l *pageLayout) line(text string, size float64, bold bool) {
+	l.reserve(size)
+	l.y -= size
+	l.add(textLine{Text: text, Size: size, Bold: bold, Align: alignLeft, X: pageMargin, Y: l.y})
+	l.y -= size * (lineSpacing - 1)
+}
+
+// entry adds a line with a second piece of text, such as a page number, against the right margin
+func (l *pageLayout) entry(text, right string, size float64) {
+	l.reserve(size)
+	l.y -= size
+	l.add(textLine{Text: text, Size: size, Align: alignLeft, X: pageMargin, Y: l.y})
+	l.add(textLine{Text: right, Size: size, Align: alignRight, X: l.width - pageMargin, Y: l.y})
+	l.y -= size * (lineSpacing - 1)
+}
+
+func (l *pageLayout) gap(size float64) {
+	l.y -= size
+}
+
+// titlePage names the story and the progs it was collected from
+func titlePage(pages []api.ExportPage, width, height float64) generatedPage {
+	m := newExportMetadata(pages)
+	y := height * 0.6
+
+	lines := make([]textLine, 0)
+	centred := func(text string, size float64, bold bool) {
+		if text == "" {
+			return
+		}
+		lines = append(lines, textLine{Text: text, Size: size, Bold: bold, Align: alignCentre, Y: y})
+		y -= size * lineSpacing * 1.5
+	}
+
+	centred(m.SeriesTitle(), titleSize, true)
+	if title := m.Title(); title != m.SeriesTitle() {
+		centred(title, subtitleSize, false)
+	}
+	if len(m.Issues) > 0 {
+		progs := "Progs"
+		if len(m.Issues) == 1 {
+			progs = "Prog"
+		}
+		centred(fmt.Sprintf("%s %s %s", strings.Join(m.Publications, " and "), progs, m.IssueRange()), issueRangeSize, false)
+	}
+	if creators := m.Creators(api.Script, api.Art); len(creators) > 0 {
+		y -= issueRangeSize
+		centred(strings.Join(creators, ", "), entrySize, false)
+	}
+
+	return generatedPage{Lines: lines}
+}
+
+// contentsPages lists each part with the page it starts on. The page numbers are offset by the number of
+// pages that will come before the entries, which must include the contents pages themselves.
+func contentsPages(entries []outlineEntry, offset int, width, height float64) []generatedPage {
+	l := newPageLayout(width, height, "Contents")
+	for _, e := range entries {
+		if e.pageThru < e.pageFrom {
+			continue
+		}
+		l.entry(episodeHeading(e.page), fmt.Sprint(e.pageFrom+offset), entrySize)
+	}
+	return l.pages
+}
+
+// countContentsPages returns how many pages the contents will take up, so that page numbers can be
+// worked out before it is laid out
+func countContentsPages(entries []outlineEntry, width, height float64) int {
+	return len(contentsPages(entries, 0, width, height))
+}
+
+// creditsPages lists the creators of each part. No pages are returned if no part has any credits.
+func creditsPages(pages []api.ExportPage, width, height float64) []generatedPage {
+	roles := []api.Role{api.Script, api.Art, api.Colours, api.Letters}
+
+	l := newPageLayout(width, height, "Credits")
+	credited := false
+	for _, p := range pages {
+		credits := make([]string, 0, len(roles))
+		for _, r := range roles {
+			if names := p.Credits[r]; len(names) > 0 {
+				credits = append(credits, fmt.Sprintf("%s%s: %s", strings.ToUpper(r.String()[:1]), r.String()[1:], strings.Join(names, ", ")))
+			}
+		}
+		if len(credits) == 0 {
+			continue
+		}
+		credited = true
+
+		sizes := []float64{entrySize}
+		for range credits {
+			sizes = append(sizes, creditSize)
+		}
+		l.reserve(sizes...)
+
+		heading := episodeHeading(p)
+		if p.IssueNumber > 0 {
+			heading = fmt.Sprintf("%s (%s)", heading, progTitle(p))
+		}
+		l.line(heading, entrySize, true)
+		for _, c := range credits {
+			l.line(c, creditSize, false)
+		}
+		l.gap(creditSize)
+	}
+	if !credited {
+		return nil
+	}
+	return l.pages
+}
+
+// episodeHeading names an episode in full, e.g. "Judge Dredd: Get Sin - Part 1"
+func episodeHeading(page api.ExportPage) string {
+	if page.Series == "" {
+		return page.Title
+	}
+	heading := page.Series
+	if page.Story != "" && page.Story != page.Series {
+		heading += ": " + page.Story
+	}
+	if page.Part > 0 {
+		heading += fmt.Sprintf(" - Part %d", page.Part)
+	}
+	return heading
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPageWidth  = 595.0
+	testPageHeight = 842.0
+)
+
+func frontMatterTestPages() []api.ExportPage {
+	return []api.ExportPage{
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2301,
+			Series:      "Judge Dredd",
+			Story:       "Get Sin",
+			Part:        1,
+			Credits:     api.Credits{api.Script: {"John Wagner"}, api.Art: {"Dan Cornwell"}, api.Letters: {"Annie Parkhouse"}},
+		},
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2302,
+			Series:      "Judge Dredd",
+			Story:       "Get Sin",
+			Part:        2,
+		},
+	}
+}
+
+func lineTexts(page generatedPage) []string {
+	texts := make([]string, 0, len(page.Lines))
+	for _, l := range page.Lines {
+		texts = append(texts, l.Text)
+	}
+	return texts
+}
+
+func TestTitlePage(t *testing.T) {
+	t.Parallel()
+	page := titlePage(frontMatterTestPages(), testPageWidth, testPageHeight)
+
+	assert.Equal(t, []string{"Judge Dredd", "Get Sin", "2000 AD Progs 2301-2302", "John Wagner, Dan Cornwell"}, lineTexts(page))
+	for i := 1; i < len(page.Lines); i++ {
+		assert.Less(t, page.Lines[i].Y, page.Lines[i-1].Y)
+	}
+}
+
+func TestContentsPages(t *testing.T) {
+	t.Parallel()
+	entries := []outlineEntry{
+		{page: frontMatterTestPages()[0], pageFrom: 1, pageThru: 6},
+		{page: frontMatterTestPages()[1], pageFrom: 7, pageThru: 12},
+	}
+
+	pages := contentsPages(entries, 2, testPageWidth, testPageHeight)
+
+	assert.Len(t, pages, 1)
+	assert.Equal(t, []string{"Contents", "Judge Dredd: Get Sin - Part 1", "3", "Judge Dredd: Get Sin - Part 2", "9"}, lineTexts(pages[0]))
+	assert.Equal(t, alignRight, pages[0].Lines[2].Align)
+}
+
+func TestContentsPages_Overflow(t *testing.T) {
+	t.Parallel()
+	entries := make([]outlineEntry, 0)
+	for i := 1; i <= 100; i++ {
+		page := api.ExportPage{Series: "Judge Dredd", Story: "The Apocalypse War", Part: i}
+		entries = append(entries, outlineEntry{page: page, pageFrom: i, pageThru: i})
+	}
+
+	pages := contentsPages(entries, 0, testPageWidth, testPageHeight)
+
+	assert.Greater(t, len(pages), 1)
+	assert.Equal(t, len(pages), countContentsPages(entries, testPageWidth, testPageHeight))
+	for _, p := range pages {
+		assert.Equal(t, "Contents", p.Lines[0].Text)
+		for _, l := range p.Lines {
+			assert.GreaterOrEqual(t, l.Y, pageMargin)
+		}
+	}
+	last := pages[len(pages)-1]
+	assert.Equal(t, fmt.Sprint(100), last.Lines[len(last.Lines)-1].Text)
+}
+
+func TestCreditsPages(t *testing.T) {
+	t.Parallel()
+	pages := creditsPages(frontMatterTestPages(), testPageWidth, testPageHeight)
+
+	assert.Len(t, pages, 1)
+	assert.Equal(t, []string{
+		"Credits",
+		"Judge Dredd: Get Sin - Part 1 (2000 AD 2301)",
+		"Script: John Wagner",
+		"Art: Dan Cornwell",
+		"Letters: Annie Parkhouse",
+	}, lineTexts(pages[0]))
+}
+
+func TestCreditsPages_NoCredits(t *testing.T) {
+	t.Parallel()
+	pages := frontMatterTestPages()
+	for i := range pages {
+		pages[i].Credits = nil
+	}
+
+	assert.Empty(t, creditsPages(pages, testPageWidth, testPageHeight))
+}
+
+func TestEpisodeHeading(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		page     api.ExportPage
+		expected string
+	}{
+		{page: api.ExportPage{Series: "Judge Dredd", Story: "Get Sin", Part: 2}, expected: "Judge Dredd: Get Sin - Part 2"},
+		{page: api.ExportPage{Series: "Brink", Story: "Brink", Part: 1}, expected: "Brink - Part 1"},
+		{page: api.ExportPage{Series: "Future Shocks", Story: "Once Upon a Time"}, expected: "Future Shocks: Once Upon a Time"},
+		{page: api.ExportPage{Title: "An Example Title"}, expected: "An Example Title"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, episodeHeading(tc.page))
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"slices"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/enums"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+const (
+	defaultRenderDPI = 200
+	jpegQuality      = 90
+)
+
+// A PageImage is a single exported page, encoded ready to be written to an archive
+type PageImage struct {
+	Data   []byte
+	Ext    string
+	Width  int
+	Height int
+	// Spread is set on an image made by joining the two halves of a double-page spread
+	Spread bool
+	// Panels are the panels found on the image, in reading order, when the extractor looks for them
+	Panels []image.Rectangle
+}
+
+// ImageExtractor turns the pages of source PDFs into images, for export formats that are built from images
+// rather than PDF pages.
+type ImageExtractor struct {
+	instance pdfium.Pdfium
+	dpi      int
+	profile  api.ExportProfile
+	savings  api.ImageSavings
+	spreads  api.SpreadOptions
+	joined   []api.JoinedSpread
+	scroll   api.ScrollOptions
+	panels   bool
+}
+
+func NewImageExtractor() *ImageExtractor {
+	return &ImageExtractor{
+		instance: Instance,
+		dpi:      defaultRenderDPI,
+	}
+}
+
+// Images returns an image for each page in the range that passes the filter, along with the pages that
+// didn't. For an artist's edition the page's background artwork is used, otherwise the page is rendered as
+// it would be displayed. Spreads are joined into a single image if the extractor has been asked to, and the
+// images are stitched into strips for a vertical scroll. Otherwise the panels on each image are found if the
+// extractor has been asked to.
+func (e *ImageExtractor) Images(page api.ExportPage, artistsEdition bool, filter *PageFilter, progress *buildProgress) ([]PageImage, []api.DroppedPage, error) {
+	source, err := e.instance.FPDF_LoadDocument(&requests.FPDF_LoadDocument{
+		Path: &page.Filename,
+	})
+	if err != nil {
+		return nil, nil, &api.PageError{Filename: page.Filename, Err: err}
+	}
+	defer e.instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: source.Document})
+
+	pages, dropped := filter.Episode(e.instance, source.Document, page)
+
+	layout, joined := layoutPages(e.instance, source.Document, page, pages, e.spreads)
+	e.joined = append(e.joined, joined...)
+
+	images := make([]PageImage, 0, len(layout))
+	for _, entry := range layout {
+		img, err := e.pageImage(source.Document, entry, artistsEdition)
+		if err == nil && !e.scroll.Enabled {
+			// A scroll's pages are only encoded once, when they're stitched, so that they aren't compressed
+			// twice
+			img, err = applyProfile(img, e.profile, &e.savings)
+		}
+		if err != nil {
+			return nil, nil, &api.PageError{Filename: page.Filename, Page: entry[0], Err: err}
+		}
+		img.Spread = len(entry) > 1
+		if e.panels && !e.scroll.Enabled {
+			if img.Panels, err = pagePanels(img); err != nil {
+				return nil, nil, &api.PageError{Filename: page.Filename, Page: entry[0], Err: err}
+			}
+		}
+		images = append(images, img)
+		if err := progress.pageAdded(page.Filename, entry[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if e.scroll.Enabled && len(images) > 0 {
+		if images, err = stitchImages(images, page.Title, e.scroll, e.profile, &e.savings); err != nil {
+			return nil, nil, &api.PageError{Filename: page.Filename, Err: err}
+		}
+	}
+	return images, dropped, nil
+}
+
+// pageImage returns the image of a page of the export, joining the halves of a spread
+func (e *ImageExtractor) pageImage(document references.FPDF_DOCUMENT, pageNums []int, artistsEdition bool) (PageImage, error) {
+	parts := make([]PageImage, 0, len(pageNums))
+	for _, pageNum := range pageNums {
+		var img PageImage
+		var err error
+		if artistsEdition {
+			img, err = e.background(document, pageNum)
+		} else {
+			img, err = e.render(document, pageNum)
+		}
+		if err != nil {
+			return PageImage{}, err
+		}
+		parts = append(parts, img)
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return joinImages(parts[0], parts[1])
+}
+
+func (e *ImageExtractor) render(document references.FPDF_DOCUMENT, pageNum int) (PageImage, error) {
+	rendered, err := e.instance.RenderPageInDPI(&requests.RenderPageInDPI{
+		Page: requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: document,
+				Index:    pageNum - 1,
+			},
+		},
+		DPI: e.dpi,
+	})
+	if err != nil {
+		return PageImage{}, err
+	}
+	if rendered.CleanupFunc != nil {
+		defer rendered.CleanupFunc()
+	}
+
+	return encodeJpeg(rendered.Result.Image)
+}
+
+// background returns the page's artwork without the lettering. A page that is a single JPEG is returned as
+// it is. Anything else, such as artwork made up of several images or stored in another encoding, is
+// rendered with everything but the images removed.
+func (e *ImageExtractor) background(document references.FPDF_DOCUMENT, pageNum int) (PageImage, error) {
+	ref, err := e.instance.FPDF_LoadPage(&requests.FPDF_LoadPage{
+		Document: document,
+		Index:    pageNum - 1,
+	})
+	if err != nil {
+		return PageImage{}, err
+	}
+	defer e.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: ref.Page})
+	page := requests.Page{ByReference: &ref.Page}
+
+	images, err := pageImageObjects(e.instance, ref.Page)
+	if err != nil {
+		return PageImage{}, err
+	}
+	if len(images) == 0 {
+		return PageImage{}, errors.New("pdf_page_object not found")
+	}
+
+	if len(images) == 1 && slices.Equal(imageFilters(e.instance, images[0]), []string{"DCTDecode"}) {
+		raw, err := e.instance.FPDFImageObj_GetImageDataRaw(&requests.FPDFImageObj_GetImageDataRaw{
+			ImageObject: images[0],
+		})
+		if err != nil {
+			return PageImage{}, err
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(raw.Data))
+		if err != nil {
+			return PageImage{}, err
+		}
+		return PageImage{Data: raw.Data, Ext: "jpg", Width: config.Width, Height: config.Height}, nil
+	}
+
+	if err := removeNonImageObjects(e.instance, ref.Page); err != nil {
+		return PageImage{}, err
+	}
+	rendered, err := e.instance.RenderPageInDPI(&requests.RenderPageInDPI{
+		Page: page,
+		DPI:  e.dpi,
+	})
+	if err != nil {
+		return PageImage{}, err
+	}
+	if rendered.CleanupFunc != nil {
+		defer rendered.CleanupFunc()
+	}
+	return encodeJpeg(rendered.Result.Image)
+}
+
+// pageImageObjects returns the image objects on a page, in the order they are drawn
+func pageImageObjects(instance pdfium.Pdfium, page references.FPDF_PAGE) ([]references.FPDF_PAGEOBJECT, error) {
+	count, err := instance.FPDFPage_CountObjects(&requests.FPDFPage_CountObjects{
+		Page: requests.Page{ByReference: &page},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]references.FPDF_PAGEOBJECT, 0, 1)
+	for i := 0; i < count.Count; i++ {
+		obj, err := instance.FPDFPage_GetObject(&requests.FPDFPage_GetObject{
+			Page:  requests.Page{ByReference: &page},
+			Index: i,
+		})
+		if err != nil {
+			return nil, err
+		}
+		t, err := instance.FPDFPageObj_GetType(&requests.FPDFPageObj_GetType{PageObject: obj.PageObject})
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == enums.FPDF_PAGEOBJ_IMAGE {
+			images = append(images, obj.PageObject)
+		}
+	}
+	return images, nil
+}
+
+// pageFormObjects returns the form objects on a page
+func pageFormObjects(instance pdfium.Pdfium, page references.FPDF_PAGE) ([]references.FPDF_PAGEOBJECT, error) {
+	count, err := instance.FPDFPage_CountObjects(&requests.FPDFPage_CountObjects{
+		Page: requests.Page{ByReference: &page},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	forms := make([]references.FPDF_PAGEOBJECT, 0)
+	for i := 0; i < count.Count; i++ {
+		obj, err := instance.FPDFPage_GetObject(&requests.FPDFPage_GetObject{
+			Page:  requests.Page{ByReference: &page},
+			Index: i,
+		})
+		if err != nil {
+			return nil, err
+		}
+		t, err := instance.FPDFPageObj_GetType(&requests.FPDFPageObj_GetType{PageObject: obj.PageObject})
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == enums.FPDF_PAGEOBJ_FORM {
+			forms = append(forms, obj.PageObject)
+		}
+	}
+	return forms, nil
+}
+
+// formImageObjects returns the image objects inside a form object, and inside any forms nested within it
+func formImageObjects(instance pdfium.Pdfium, form references.FPDF_PAGEOBJECT) ([]references.FPDF_PAGEOBJECT, error) {
+	count, err := instance.FPDFFormObj_CountObjects(&requests.FPDFFormObj_CountObjects{PageObject: form})
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]references.FPDF_PAGEOBJECT, 0, 1)
+	for i := 0; i < count.Count; i++ {
+		obj, err := instance.FPDFFormObj_GetObject(&requests.FPDFFormObj_GetObject{
+			PageObject: form,
+			Index:      uint64(i),
+		})
+		if err != nil {
+			return nil, err
+		}
+		t, err := instance.FPDFPageObj_GetType(&requests.FPDFPageObj_GetType{PageObject: obj.PageObject})
+		if err != nil {
+			return nil, err
+		}
+		switch t.Type {
+		case enums.FPDF_PAGEOBJ_IMAGE:
+			images = append(images, obj.PageObject)
+		case enums.FPDF_PAGEOBJ_FORM:
+			nested, err := formImageObjects(instance, obj.PageObject)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, nested...)
+		}
+	}
+	return images, nil
+}
+
+// removeNonImageObjects strips the text and vector objects from a loaded page. The change is never saved,
+// it only affects how the page renders.
+func removeNonImageObjects(instance pdfium.Pdfium, page references.FPDF_PAGE) error {
+	count, err := instance.FPDFPage_CountObjects(&requests.FPDFPage_CountObjects{
+		Page: requests.Page{ByReference: &page},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Work backwards so that removing an object doesn't shift the ones still to be checked
+	for i := count.Count - 1; i >= 0; i-- {
+		obj, err := instance.FPDFPage_GetObject(&requests.FPDFPage_GetObject{
+			Page:  requests.Page{ByReference: &page},
+			Index: i,
+		})
+		if err != nil {
+			return err
+		}
+		t, err := instance.FPDFPageObj_GetType(&requests.FPDFPageObj_GetType{PageObject: obj.PageObject})
+		if err != nil {
+			return err
+		}
+		if t.Type == enums.FPDF_PAGEOBJ_IMAGE {
+			continue
+		}
+		if _, err := instance.FPDFPage_RemoveObject(&requests.FPDFPage_RemoveObject{
+			Page:       requests.Page{ByReference: &page},
+			PageObject: obj.PageObject,
+		}); err != nil {
+			return err
+		}
+		instance.FPDFPageObj_Destroy(&requests.FPDFPageObj_Destroy{PageObject: obj.PageObject})
+	}
+
+	_, err = instance.FPDFPage_GenerateContent(&requests.FPDFPage_GenerateContent{
+		Page: requests.Page{ByReference: &page},
+	})
+	return err
+}
+
+// imageFilters returns the names of the filters used to encode an image object's data
+func imageFilters(instance pdfium.Pdfium, imageObject references.FPDF_PAGEOBJECT) []string {
+	filters := make([]string, 0, 1)
+	count, err := instance.FPDFImageObj_GetImageFilterCount(&requests.FPDFImageObj_GetImageFilterCount{
+		ImageObject: imageObject,
+	})
+	if err != nil {
+		return filters
+	}
+	for i := 0; i < count.Count; i++ {
+		if f, err := instance.FPDFImageObj_GetImageFilter(&requests.FPDFImageObj_GetImageFilter{
+			ImageObject: imageObject,
+			Index:       i,
+		}); err == nil {
+			filters = append(filters, f.ImageFilter)
+		}
+	}
+	return filters
+}
+
+func encodeJpeg(img image.Image) (PageImage, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return PageImage{}, err
+	}
+	bounds := img.Bounds()
+	return PageImage{Data: buf.Bytes(), Ext: "jpg", Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/chooban/progger/scan/api"
+)
+
+// exportMetadata describes the contents of an export, gathered from its pages, for the formats that can
+// carry it.
+type exportMetadata struct {
+	Series       []string
+	Stories      []string
+	Publications []string
+	Issues       []int
+	Credits      api.Credits
+}
+
+func newExportMetadata(pages []api.ExportPage) exportMetadata {
+	m := exportMetadata{
+		Series:       make([]string, 0),
+		Stories:      make([]string, 0),
+		Publications: make([]string, 0),
+		Issues:       make([]int, 0),
+		Credits:      api.Credits{},
+	}
+	appendUnique := func(s []string, v string) []string {
+		if v == "" || slices.Contains(s, v) {
+			return s
+		}
+		return append(s, v)
+	}
+
+	for _, p := range pages {
+		m.Series = appendUnique(m.Series, p.Series)
+		m.Stories = appendUnique(m.Stories, p.Story)
+		m.Publications = appendUnique(m.Publications, p.Publication)
+		if p.IssueNumber > 0 && !slices.Contains(m.Issues, p.IssueNumber) {
+			m.Issues = append(m.Issues, p.IssueNumber)
+		}
+		for role, names := range p.Credits {
+			if role == api.Unknown {
+				continue
+			}
+			for _, name := range names {
+				m.Credits[role] = appendUnique(m.Credits[role], name)
+			}
+		}
+	}
+	slices.Sort(m.Issues)
+
+	return m
+}
+
+// DisplayTitle names the export in the way the exporter names stories, e.g. "Judge Dredd - Get Sin"
+func (m exportMetadata) DisplayTitle() string {
+	series, title := m.SeriesTitle(), m.Title()
+	switch {
+	case title == "" || title == series:
+		return series
+	case series == "":
+		return title
+	}
+	return series + " - " + title
+}
+
+// Title is the story title, or titles, of the export
+func (m exportMetadata) Title() string {
+	return strings.Join(m.Stories, "; ")
+}
+
+// SeriesTitle is the series, or series, of the export
+func (m exportMetadata) SeriesTitle() string {
+	return strings.Join(m.Series, " / ")
+}
+
+// Creators returns the credited names for the given roles, in the order they first appear
+func (m exportMetadata) Creators(roles ...api.Role) []string {
+	creators := make([]string, 0)
+	for _, r := range roles {
+		for _, name := range m.Credits[r] {
+			if !slices.Contains(creators, name) {
+				creators = append(creators, name)
+			}
+		}
+	}
+	return creators
+}
+
+// IssueRange describes the issues in the export, collapsing consecutive runs. e.g. "2300-2302, 2305"
+func (m exportMetadata) IssueRange() string {
+	return issueRange(m.Issues)
+}
+
+// Summary is a sentence describing the export's contents, suitable for a description field
+func (m exportMetadata) Summary() string {
+	summary := m.SeriesTitle()
+	if title := m.Title(); title != "" && title != summary {
+		summary = fmt.Sprintf("%s: %s", summary, title)
+	}
+	if len(m.Issues) > 0 {
+		progs := "Progs"
+		if len(m.Issues) == 1 {
+			progs = "Prog"
+		}
+		summary = fmt.Sprintf("%s, from %s %s %s", summary, strings.Join(m.Publications, " and "), progs, m.IssueRange())
+	}
+	return summary
+}
+
+func issueRange(issues []int) string {
+	sorted := slices.Clone(issues)
+	slices.Sort(sorted)
+	return formatRanges(slices.Compact(sorted), ", ")
+}
+
+// formatRanges joins sorted numbers, collapsing consecutive runs into ranges
+func formatRanges(numbers []int, sep string) string {
+	ranges := make([]string, 0)
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(numbers[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", numbers[i], numbers[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, sep)
+}
+
+func escapeXml(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
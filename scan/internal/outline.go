@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// outlineEntry is an episode's place in the exported document
+type outlineEntry struct {
+	page     api.ExportPage
+	pageFrom int
+	pageThru int
+}
+
+// buildOutline nests bookmarks as series, then story, then part, optionally with the source prog beneath
+// each part. Stories named after their series sit directly under the series. Pages without a series keep
+// their title as a flat bookmark. Siblings are kept in page order, as pdfcpu requires.
+func buildOutline(entries []outlineEntry, progs bool) []pdfcpu.Bookmark {
+	outline := make([]pdfcpu.Bookmark, 0)
+	for _, e := range entries {
+		if e.pageThru < e.pageFrom {
+			continue
+		}
+		if e.page.Series == "" {
+			if e.page.Title != "" {
+				outline = append(outline, pdfcpu.Bookmark{Title: e.page.Title, PageFrom: e.pageFrom, PageThru: e.pageThru})
+			}
+			continue
+		}
+
+		series := findOrAddBookmark(&outline, e.page.Series, e)
+		parent := series
+		if e.page.Story != "" && e.page.Story != e.page.Series {
+			parent = findOrAddBookmark(&series.Kids, e.page.Story, e)
+		}
+
+		part := pdfcpu.Bookmark{Title: partTitle(e.page), PageFrom: e.pageFrom, PageThru: e.pageThru}
+		if progs && e.page.IssueNumber > 0 {
+			part.Kids = []pdfcpu.Bookmark{{
+				Title:    progTitle(e.page),
+				PageFrom: e.pageFrom,
+				PageThru: e.pageThru,
+			}}
+		}
+		parent.Kids = append(parent.Kids, part)
+	}
+
+	sortOutline(outline)
+	return outline
+}
+
+// findOrAddBookmark returns the bookmark with the given title, adding it if needed, and widens its page
+// range to cover the entry.
+func findOrAddBookmark(bookmarks *[]pdfcpu.Bookmark, title string, e outlineEntry) *pdfcpu.Bookmark {
+	idx := slices.IndexFunc(*bookmarks, func(b pdfcpu.Bookmark) bool { return b.Title == title })
+	if idx < 0 {
+		*bookmarks = append(*bookmarks, pdfcpu.Bookmark{
+			Title:    title,
+			PageFrom: e.pageFrom,
+			PageThru: e.pageThru,
+			Kids:     make([]pdfcpu.Bookmark, 0),
+		})
+		idx = len(*bookmarks) - 1
+	}
+	b := &(*bookmarks)[idx]
+	b.PageFrom = min(b.PageFrom, e.pageFrom)
+	b.PageThru = max(b.PageThru, e.pageThru)
+	return b
+}
+
+func sortOutline(bookmarks []pdfcpu.Bookmark) {
+	slices.SortStableFunc(bookmarks, func(a, b pdfcpu.Bookmark) int {
+		return cmp.Compare(a.PageFrom, b.PageFrom)
+	})
+	for i := range bookmarks {
+		sortOutline(bookmarks[i].Kids)
+	}
+}
+
+func partTitle(page api.ExportPage) string {
+	if page.Part > 0 {
+		return fmt.Sprintf("Part %d", page.Part)
+	}
+	if page.Title != "" {
+		return page.Title
+	}
+	return page.Story
+}
+
+func progTitle(page api.ExportPage) string {
+	publication := page.Publication
+	if publication == "" {
+		publication = "Prog"
+	}
+	return fmt.Sprintf("%s %d", publication, page.IssueNumber)
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildOutline(t *testing.T) {
+	t.Parallel()
+	dredd := func(part, issue int) api.ExportPage {
+		return api.ExportPage{Series: "Judge Dredd", Story: "Get Sin", Part: part, IssueNumber: issue, Publication: "2000 AD"}
+	}
+	brink := func(part, issue int) api.ExportPage {
+		return api.ExportPage{Series: "Brink", Story: "Brink", Part: part, IssueNumber: issue, Publication: "2000 AD"}
+	}
+
+	testCases := []struct {
+		name     string
+		entries  []outlineEntry
+		progs    bool
+		expected []pdfcpu.Bookmark
+	}{
+		{
+			name: "Series, story and parts",
+			entries: []outlineEntry{
+				{page: dredd(1, 2301), pageFrom: 1, pageThru: 6},
+				{page: dredd(2, 2302), pageFrom: 7, pageThru: 12},
+			},
+			expected: []pdfcpu.Bookmark{
+				{Title: "Judge Dredd", PageFrom: 1, PageThru: 12, Kids: []pdfcpu.Bookmark{
+					{Title: "Get Sin", PageFrom: 1, PageThru: 12, Kids: []pdfcpu.Bookmark{
+						{Title: "Part 1", PageFrom: 1, PageThru: 6},
+						{Title: "Part 2", PageFrom: 7, PageThru: 12},
+					}},
+				}},
+			},
+		},
+		{
+			name: "Interleaved stories are grouped",
+			entries: []outlineEntry{
+				{page: dredd(1, 2301), pageFrom: 1, pageThru: 6},
+				{page: brink(1, 2301), pageFrom: 7, pageThru: 12},
+				{page: dredd(2, 2302), pageFrom: 13, pageThru: 18},
+			},
+			expected: []pdfcpu.Bookmark{
+				{Title: "Judge Dredd", PageFrom: 1, PageThru: 18, Kids: []pdfcpu.Bookmark{
+					{Title: "Get Sin", PageFrom: 1, PageThru: 18, Kids: []pdfcpu.Bookmark{
+						{Title: "Part 1", PageFrom: 1, PageThru: 6},
+						{Title: "Part 2", PageFrom: 13, PageThru: 18},
+					}},
+				}},
+				{Title: "Brink", PageFrom: 7, PageThru: 12, Kids: []pdfcpu.Bookmark{
+					{Title: "Part 1", PageFrom: 7, PageThru: 12},
+				}},
+			},
+		},
+		{
+			name: "Progs beneath parts",
+			entries: []outlineEntry{
+				{page: brink(3, 2305), pageFrom: 1, pageThru: 6},
+			},
+			progs: true,
+			expected: []pdfcpu.Bookmark{
+				{Title: "Brink", PageFrom: 1, PageThru: 6, Kids: []pdfcpu.Bookmark{
+					{Title: "Part 3", PageFrom: 1, PageThru: 6, Kids: []pdfcpu.Bookmark{
+						{Title: "2000 AD 2305", PageFrom: 1, PageThru: 6},
+					}},
+				}},
+			},
+		},
+		{
+			name: "Pages without a series stay flat, empty ranges are dropped",
+			entries: []outlineEntry{
+				{page: api.ExportPage{Title: "An Example Title"}, pageFrom: 1, pageThru: 4},
+				{page: dredd(1, 2301), pageFrom: 5, pageThru: 4},
+			},
+			expected: []pdfcpu.Bookmark{
+				{Title: "An Example Title", PageFrom: 1, PageThru: 4},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, buildOutline(tc.entries, tc.progs))
+		})
+	}
+}
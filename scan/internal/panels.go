@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// panelScanSize is the longest side pages are shrunk to before looking for panels. Gutters are wide
+	// enough to survive it, and it keeps the scan quick.
+	panelScanSize = 600
+	// panelTolerance is how far, in grey levels, a pixel may be from the page's background and still count
+	// as gutter
+	panelTolerance = 32
+	// panelMinSize is the smallest a panel may be, as a fraction of the page's width and height. Anything
+	// smaller is lettering or a page number sitting in the gutter.
+	panelMinSize = 0.08
+	// panelMaxDepth stops the page being cut up indefinitely
+	panelMaxDepth = 8
+)
+
+// panelGrid is a page shrunk to greys, along with the colour of its gutters
+type panelGrid struct {
+	grey       *image.Gray
+	background uint8
+	minGutter  int
+}
+
+// pagePanels decodes a page image and returns its panels, in the image's own pixels
+func pagePanels(img PageImage) ([]image.Rectangle, error) {
+	decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, err
+	}
+	return detectPanels(decoded), nil
+}
+
+// detectPanels finds the panels of a comic page by the gutters between them, in reading order: rows from
+// top to bottom, and the panels of each row from left to right. The page is cut along any band of rows or
+// columns that is entirely the colour of the page's margins, then each piece is cut again, until no gutters
+// are left. A page with no gutters, such as a splash page, is a single panel, and a blank page has none.
+func detectPanels(img image.Image) []image.Rectangle {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return []image.Rectangle{}
+	}
+	scale := min(1, float64(panelScanSize)/float64(max(bounds.Dx(), bounds.Dy())))
+	w, h := max(1, int(float64(bounds.Dx())*scale)), max(1, int(float64(bounds.Dy())*scale))
+	grey := image.NewGray(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(grey, grey.Bounds(), img, bounds, draw.Src, nil)
+
+	g := &panelGrid{
+		grey:       grey,
+		background: marginColour(grey),
+		minGutter:  max(2, max(w, h)/150),
+	}
+	panels := make([]image.Rectangle, 0)
+	for _, p := range g.cut(grey.Bounds(), 0) {
+		if float64(p.Dx()) < panelMinSize*float64(w) || float64(p.Dy()) < panelMinSize*float64(h) {
+			continue
+		}
+		panels = append(panels, image.Rect(
+			bounds.Min.X+int(float64(p.Min.X)/scale),
+			bounds.Min.Y+int(float64(p.Min.Y)/scale),
+			bounds.Min.X+min(bounds.Dx(), int(float64(p.Max.X)/scale+0.5)),
+			bounds.Min.Y+min(bounds.Dy(), int(float64(p.Max.Y)/scale+0.5)),
+		))
+	}
+	return panels
+}
+
+// marginColour is the most common grey around the edge of the page, which is taken to be the colour of
+// its gutters
+func marginColour(grey *image.Gray) uint8 {
+	counts := [256]int{}
+	b := grey.Bounds()
+	for x := b.Min.X; x < b.Max.X; x++ {
+		counts[grey.GrayAt(x, b.Min.Y).Y]++
+		counts[grey.GrayAt(x, b.Max.Y-1).Y]++
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		counts[grey.GrayAt(b.Min.X, y).Y]++
+		counts[grey.GrayAt(b.Max.X-1, y).Y]++
+	}
+	// Scans are noisy, so nearby greys count towards each other
+	best, bestCount := 0, -1
+	for level := range counts {
+		count := 0
+		for l := max(0, level-8); l <= min(255, level+8); l++ {
+			count += counts[l]
+		}
+		if count > bestCount {
+			best, bestCount = level, count
+		}
+	}
+	return uint8(best)
+}
+
+// cut splits a region of the page along its gutters, first into rows and then into columns, and cuts each
+// piece again
+func (g *panelGrid) cut(r image.Rectangle, depth int) []image.Rectangle {
+	r = g.trim(r)
+	if r.Empty() {
+		return []image.Rectangle{}
+	}
+	if depth < panelMaxDepth {
+		for _, horizontal := range []bool{true, false} {
+			parts := g.split(r, horizontal)
+			if len(parts) < 2 {
+				continue
+			}
+			panels := make([]image.Rectangle, 0, len(parts))
+			for _, p := range parts {
+				panels = append(panels, g.cut(p, depth+1)...)
+			}
+			return panels
+		}
+	}
+	return []image.Rectangle{r}
+}
+
+// trim shrinks a region until each of its edges touches something that isn't gutter
+func (g *panelGrid) trim(r image.Rectangle) image.Rectangle {
+	for !r.Empty() && g.isGutter(r, r.Min.Y, true) {
+		r.Min.Y++
+	}
+	for !r.Empty() && g.isGutter(r, r.Max.Y-1, true) {
+		r.Max.Y--
+	}
+	for !r.Empty() && g.isGutter(r, r.Min.X, false) {
+		r.Min.X++
+	}
+	for !r.Empty() && g.isGutter(r, r.Max.X-1, false) {
+		r.Max.X--
+	}
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	return r
+}
+
+// split cuts a trimmed region into the pieces between its gutters, as rows if horizontal and otherwise as
+// columns. Bands of gutter thinner than the minimum are taken to be part of the artwork.
+func (g *panelGrid) split(r image.Rectangle, horizontal bool) []image.Rectangle {
+	from, to := r.Min.X, r.Max.X
+	if horizontal {
+		from, to = r.Min.Y, r.Max.Y
+	}
+	piece := func(start, end int) image.Rectangle {
+		if horizontal {
+			return image.Rect(r.Min.X, start, r.Max.X, end)
+		}
+		return image.Rect(start, r.Min.Y, end, r.Max.Y)
+	}
+
+	parts := make([]image.Rectangle, 0, 1)
+	start, gutter := from, 0
+	for i := from; i < to; i++ {
+		if g.isGutter(r, i, horizontal) {
+			gutter++
+			continue
+		}
+		if gutter >= g.minGutter {
+			parts = append(parts, piece(start, i-gutter))
+			start = i
+		}
+		gutter = 0
+	}
+	return append(parts, piece(start, to))
+}
+
+// isGutter reports whether a row (when horizontal) or column of a region is all, or nearly all, the
+// colour of the gutters
+func (g *panelGrid) isGutter(r image.Rectangle, at int, horizontal bool) bool {
+	from, to := r.Min.Y, r.Max.Y
+	if horizontal {
+		from, to = r.Min.X, r.Max.X
+	}
+	allowed := (to - from) / 100
+	inked := 0
+	for i := from; i < to; i++ {
+		var v uint8
+		if horizontal {
+			v = g.grey.GrayAt(i, at).Y
+		} else {
+			v = g.grey.GrayAt(at, i).Y
+		}
+		if int(v) < int(g.background)-panelTolerance || int(v) > int(g.background)+panelTolerance {
+			inked++
+			if inked > allowed {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/draw"
+)
+
+// comicPage draws a white page with a black-bordered panel, filled with artwork, in each rectangle
+func comicPage(width, height int, panels ...image.Rectangle) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, p := range panels {
+		draw.Draw(img, p, image.NewUniform(color.Black), image.Point{}, draw.Src)
+		draw.Draw(img, p.Inset(3), artwork(p.Dx()-6, p.Dy()-6, 0, 0), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// closeTo checks that each panel found is within a few pixels of the one expected, allowing for the page
+// being shrunk before it is scanned
+func closeTo(t *testing.T, expected, actual []image.Rectangle) {
+	t.Helper()
+	if !assert.Len(t, actual, len(expected)) {
+		return
+	}
+	for i := range expected {
+		assert.InDelta(t, expected[i].Min.X, actual[i].Min.X, 4, "panel %d", i)
+		assert.InDelta(t, expected[i].Min.Y, actual[i].Min.Y, 4, "panel %d", i)
+		assert.InDelta(t, expected[i].Max.X, actual[i].Max.X, 4, "panel %d", i)
+		assert.InDelta(t, expected[i].Max.Y, actual[i].Max.Y, 4, "panel %d", i)
+	}
+}
+
+func TestDetectPanels(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name   string
+		width  int
+		height int
+		panels []image.Rectangle
+	}{
+		{
+			name:   "Two rows, the first split in two",
+			width:  800,
+			height: 1200,
+			panels: []image.Rectangle{
+				image.Rect(40, 40, 380, 580),
+				image.Rect(420, 40, 760, 580),
+				image.Rect(40, 620, 760, 1160),
+			},
+		},
+		{
+			name:   "Tall panel beside two stacked panels",
+			width:  800,
+			height: 1200,
+			panels: []image.Rectangle{
+				image.Rect(40, 40, 380, 1160),
+				image.Rect(420, 40, 760, 580),
+				image.Rect(420, 620, 760, 1160),
+			},
+		},
+		{
+			name:   "Splash page",
+			width:  400,
+			height: 600,
+			panels: []image.Rectangle{image.Rect(20, 20, 380, 580)},
+		},
+		{
+			name:   "Blank page",
+			width:  400,
+			height: 600,
+			panels: []image.Rectangle{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			closeTo(t, tc.panels, detectPanels(comicPage(tc.width, tc.height, tc.panels...)))
+		})
+	}
+}
+
+func TestDetectPanels_IgnoresPageNumbers(t *testing.T) {
+	t.Parallel()
+	img := comicPage(400, 600, image.Rect(20, 20, 380, 560)).(*image.Gray)
+	draw.Draw(img, image.Rect(190, 575, 210, 590), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	closeTo(t, []image.Rectangle{image.Rect(20, 20, 380, 560)}, detectPanels(img))
+}
+
+func TestPagePanels(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, comicPage(400, 600, image.Rect(20, 20, 380, 280), image.Rect(20, 320, 380, 580)), nil))
+
+	panels, err := pagePanels(PageImage{Data: buf.Bytes(), Ext: "jpg", Width: 400, Height: 600})
+	assert.NoError(t, err)
+	closeTo(t, []image.Rectangle{image.Rect(20, 20, 380, 280), image.Rect(20, 320, 380, 580)}, panels)
+
+	_, err = pagePanels(PageImage{Data: []byte("not an image")})
+	assert.Error(t, err)
+}
@@ -7,6 +7,7 @@ type PdfBookmark struct {
 }
 
 type EpisodeDetails struct {
-	Bookmark PdfBookmark
-	Credits  string
+	Bookmark      PdfBookmark
+	Credits       string
+	FirstPageText string
 }
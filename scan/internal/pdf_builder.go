@@ -1,277 +1,823 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+
 	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
 	"github.com/klippa-app/go-pdfium"
-	"github.com/klippa-app/go-pdfium/enums"
+	"github.com/klippa-app/go-pdfium/references"
 	"github.com/klippa-app/go-pdfium/requests"
-	"github.com/klippa-app/go-pdfium/responses"
 	"github.com/klippa-app/go-pdfium/structs"
 	pdfApi "github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
-	"regexp"
-	"strings"
 )
 
 type PdfBuilder struct {
-	BuildError  error
 	instance    pdfium.Pdfium
-	destination *responses.FPDF_CreateNewDocument
-	savedAs     *responses.FPDF_SaveAsCopy
+	images      *ImageExtractor
+	filter      *PageFilter
+	dropped     []api.DroppedPage
+	profile     api.ExportProfile
+	savings     api.ImageSavings
+	spreads     api.SpreadOptions
+	joined      []api.JoinedSpread
+	destination references.FPDF_DOCUMENT
 }
 
 func NewPdfBuilder() *PdfBuilder {
 	return &PdfBuilder{
 		instance: Instance,
+		images:   NewImageExtractor(),
 	}
 }
 
-func (p *PdfBuilder) OpenDestination() {
-	p.destination, p.BuildError = p.instance.FPDF_CreateNewDocument(&requests.FPDF_CreateNewDocument{})
+// loadSource opens a source file, returning a function that closes it again
+func (p *PdfBuilder) loadSource(sourceFile string) (references.FPDF_DOCUMENT, func(), error) {
+	source, err := p.instance.FPDF_LoadDocument(&requests.FPDF_LoadDocument{
+		Path: &sourceFile,
+	})
+	if err != nil {
+		return "", nil, &api.PageError{Filename: sourceFile, Err: err}
+	}
+	return source.Document, func() {
+		p.instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: source.Document})
+	}, nil
 }
 
-func (p *PdfBuilder) CopyStrippedPages(sourceFile *string, pageFrom, pageTo, insertIndex int) (pagesAdded int) {
-	println(fmt.Sprintf("Copying pages %d to %d", pageFrom, pageTo))
-	if p.BuildError != nil {
-		return 0
+// CopyStrippedPages copies the artwork of each page without the lettering. Every image on the source page
+// is copied with the transform it had there, so backgrounds made up of several images, or of images at
+// different resolutions, are laid out as they were. Only the text and vector objects are left behind, along
+// with any images nested inside form objects, whose transforms are relative to the form rather than the page.
+func (p *PdfBuilder) CopyStrippedPages(episode api.ExportPage, insertIndex int, progress *buildProgress) (pagesAdded int, err error) {
+	source, closeSource, err := p.loadSource(episode.Filename)
+	if err != nil {
+		return 0, err
 	}
-	var source *responses.FPDF_LoadDocument
-	if source, p.BuildError = p.instance.FPDF_LoadDocument(&requests.FPDF_LoadDocument{
-		Path: sourceFile,
-	}); p.BuildError != nil {
-		return
+	defer closeSource()
+
+	layout := p.layoutPages(episode, source, p.filterPages(episode, source))
+	for _, entry := range layout {
+		if err := p.copyStrippedPage(source, entry, insertIndex+pagesAdded); err != nil {
+			return pagesAdded, &api.PageError{Filename: episode.Filename, Page: entry[0], Err: err}
+		}
+		if err := p.resamplePage(insertIndex + pagesAdded); err != nil {
+			return pagesAdded, &api.PageError{Filename: episode.Filename, Page: entry[0], Err: err}
+		}
+		pagesAdded++
+		if err := progress.pageAdded(episode.Filename, entry[0]); err != nil {
+			return pagesAdded, err
+		}
 	}
+	return pagesAdded, nil
+}
 
-	// Sometimes the tail end of an episode has adverts. We can try and filter them out
-	for pageIndex := pageTo; pageIndex > pageFrom; pageIndex-- {
-		if p.shouldSkipPage(source, pageIndex) {
-			println(fmt.Sprintf("Skipping page %d", pageIndex))
-			pageTo--
-			continue
+// sourcePage is a page loaded from a source document, placed at an offset across a new page
+type sourcePage struct {
+	page   references.FPDF_PAGE
+	width  float64
+	height float64
+	// box is the page's bounding box. Object transforms are relative to the page's origin, which isn't
+	// always at zero.
+	box    structs.FPDF_FS_RECTF
+	offset float64
+}
+
+// loadSourcePages loads pages of a source document to be laid out side by side, returning them along with
+// the size of the page they fill and a function that closes them again
+func (p *PdfBuilder) loadSourcePages(source references.FPDF_DOCUMENT, pageNums []int) (pages []sourcePage, width, height float64, closePages func(), err error) {
+	closePages = func() {
+		for _, sp := range pages {
+			p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: sp.page})
+		}
+	}
+	for _, pageNum := range pageNums {
+		sp, err := p.loadSourcePage(source, pageNum)
+		if err != nil {
+			closePages()
+			return nil, 0, 0, nil, err
 		}
+		sp.offset = width
+		pages = append(pages, sp)
+		width += sp.width
+		height = max(height, sp.height)
+	}
+	return pages, width, height, closePages, nil
+}
 
-		// If we didn't continue then assume we're into episode pages. Conceivably, the phrase "on sale now" might
-		// be in the dialogue, so going through all the pages doesn't make sense.
-		break
+func (p *PdfBuilder) loadSourcePage(source references.FPDF_DOCUMENT, pageNum int) (sourcePage, error) {
+	ref, err := p.instance.FPDF_LoadPage(&requests.FPDF_LoadPage{
+		Document: source,
+		Index:    pageNum - 1,
+	})
+	if err != nil {
+		return sourcePage{}, err
 	}
-	for pageNum := pageFrom; pageNum <= pageTo; pageNum++ {
-		ref, err := p.instance.FPDF_LoadPage(&requests.FPDF_LoadPage{
-			Document: source.Document,
-			Index:    pageNum - 1,
-		})
+	page := requests.Page{ByReference: &ref.Page}
+	fail := func(err error) (sourcePage, error) {
+		p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: ref.Page})
+		return sourcePage{}, err
+	}
+
+	width, err := p.instance.FPDF_GetPageWidth(&requests.FPDF_GetPageWidth{Page: page})
+	if err != nil {
+		return fail(err)
+	}
+	height, err := p.instance.FPDF_GetPageHeight(&requests.FPDF_GetPageHeight{Page: page})
+	if err != nil {
+		return fail(err)
+	}
+	box, err := p.instance.FPDF_GetPageBoundingBox(&requests.FPDF_GetPageBoundingBox{Page: page})
+	if err != nil {
+		return fail(err)
+	}
+	return sourcePage{page: ref.Page, width: width.Width, height: height.Height, box: box.Rect}, nil
+}
+
+// copyStrippedPage adds a page made from the images of the source pages. A single source page is copied at
+// its own size. The two halves of a spread are laid side by side on a page wide enough for both. Only images
+// drawn directly on the page are copied; those inside form objects are skipped.
+func (p *PdfBuilder) copyStrippedPage(source references.FPDF_DOCUMENT, pageNums []int, insertIndex int) error {
+	sourcePages, width, height, closePages, err := p.loadSourcePages(source, pageNums)
+	if err != nil {
+		return err
+	}
+	defer closePages()
+
+	newPage, err := p.instance.FPDFPage_New(&requests.FPDFPage_New{
+		Document:  p.destination,
+		PageIndex: insertIndex,
+		Width:     width,
+		Height:    height,
+	})
+	if err != nil {
+		return err
+	}
+	defer p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: newPage.Page})
+	destinationPage := requests.Page{ByReference: &newPage.Page}
+
+	for _, sp := range sourcePages {
+		images, err := pageImageObjects(p.instance, sp.page)
 		if err != nil {
-			p.BuildError = err
-			return
-		}
-		width, _ := p.instance.FPDF_GetPageWidth(&requests.FPDF_GetPageWidth{requests.Page{ByReference: &ref.Page}})
-		height, _ := p.instance.FPDF_GetPageHeight(&requests.FPDF_GetPageHeight{requests.Page{ByReference: &ref.Page}})
-
-		var res *responses.FPDFPage_CountObjects
-		if res, err = p.instance.FPDFPage_CountObjects(&requests.FPDFPage_CountObjects{
-			requests.Page{
-				ByReference: &ref.Page,
-				ByIndex:     nil,
-			},
-		}); err != nil {
-			p.BuildError = err
-			return
+			return err
+		}
+		if len(images) == 0 {
+			return fmt.Errorf("pdf_page_object not found")
 		}
 
-		var bgObject *responses.FPDFPage_GetObject
-		for i := 0; i < res.Count; i++ {
-			obj, _ := p.instance.FPDFPage_GetObject(&requests.FPDFPage_GetObject{
-				Page:  requests.Page{ByReference: &ref.Page},
-				Index: i,
+		for _, image := range images {
+			newImage, err := p.instance.FPDFPageObj_NewImageObj(&requests.FPDFPageObj_NewImageObj{
+				Document: p.destination,
 			})
-			t, _ := p.instance.FPDFPageObj_GetType(&requests.FPDFPageObj_GetType{PageObject: obj.PageObject})
-			if t.Type != enums.FPDF_PAGEOBJ_IMAGE {
-				continue
-			} else {
-				bgObject = obj
-				break
+			if err != nil {
+				return err
+			}
+			if err := p.copyImageData(image, newImage.PageObject, destinationPage); err != nil {
+				return err
+			}
+
+			matrix, err := p.instance.FPDFPageObj_GetMatrix(&requests.FPDFPageObj_GetMatrix{PageObject: image})
+			if err != nil {
+				return err
+			}
+			transform := matrix.Matrix
+			transform.E += float32(sp.offset) - sp.box.Left
+			transform.F -= sp.box.Bottom
+			if _, err := p.instance.FPDFPageObj_SetMatrix(&requests.FPDFPageObj_SetMatrix{
+				PageObject: newImage.PageObject,
+				Transform:  transform,
+			}); err != nil {
+				return err
+			}
+
+			if _, err := p.instance.FPDFPage_InsertObject(&requests.FPDFPage_InsertObject{
+				Page:       destinationPage,
+				PageObject: newImage.PageObject,
+			}); err != nil {
+				return err
 			}
 		}
-		if bgObject == nil {
-			p.BuildError = fmt.Errorf("pdf_page_object not found")
-			return
-		}
+	}
 
-		// This is the raw, compressed image. We want to then embed that in a new page somehow
-		rawImage, _ := p.instance.FPDFImageObj_GetImageDataRaw(&requests.FPDFImageObj_GetImageDataRaw{
-			ImageObject: bgObject.PageObject,
-		})
-		newImage, _ := p.instance.FPDFPageObj_NewImageObj(&requests.FPDFPageObj_NewImageObj{
-			Document: p.destination.Document,
-		})
-		newPage, _ := p.instance.FPDFPage_New(&requests.FPDFPage_New{
-			Document:  p.destination.Document,
-			PageIndex: insertIndex + pagesAdded,
-			Width:     width.Width,
-			Height:    height.Height,
+	_, err = p.instance.FPDFPage_GenerateContent(&requests.FPDFPage_GenerateContent{Page: destinationPage})
+	return err
+}
+
+// joinPages adds a page with the two halves of a spread drawn side by side. Each half is copied whole, as
+// a form object, so that its lettering and vector artwork come along with its images.
+func (p *PdfBuilder) joinPages(source references.FPDF_DOCUMENT, pageNums []int, insertIndex int) error {
+	sourcePages, width, height, closePages, err := p.loadSourcePages(source, pageNums)
+	if err != nil {
+		return err
+	}
+	defer closePages()
+
+	newPage, err := p.instance.FPDFPage_New(&requests.FPDFPage_New{
+		Document:  p.destination,
+		PageIndex: insertIndex,
+		Width:     width,
+		Height:    height,
+	})
+	if err != nil {
+		return err
+	}
+	defer p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: newPage.Page})
+	destinationPage := requests.Page{ByReference: &newPage.Page}
+
+	for i, sp := range sourcePages {
+		xObject, err := p.instance.FPDF_NewXObjectFromPage(&requests.FPDF_NewXObjectFromPage{
+			Source:          source,
+			Destination:     p.destination,
+			SourcePageIndex: pageNums[i] - 1,
 		})
-		if _, err := p.instance.FPDFImageObj_LoadJpegFileInline(&requests.FPDFImageObj_LoadJpegFileInline{
-			Page: &requests.Page{
-				ByReference: &newPage.Page,
-			},
-			ImageObject: newImage.PageObject,
-			FileData:    rawImage.Data,
-			Count:       0,
-		}); err != nil {
-			p.BuildError = err
-			return
-		}
-		meta, _ := p.instance.FPDFImageObj_GetImageMetadata(&requests.FPDFImageObj_GetImageMetadata{
-			ImageObject: bgObject.PageObject,
-			Page: requests.Page{
-				ByReference: &ref.Page,
-			},
+		if err != nil {
+			return err
+		}
+		form, err := p.instance.FPDF_NewFormObjectFromXObject(&requests.FPDF_NewFormObjectFromXObject{
+			XObject: xObject.XObject,
 		})
-
-		if _, err = p.instance.FPDFPageObj_Transform(&requests.FPDFPageObj_Transform{
-			PageObject: newImage.PageObject,
-			Transform: structs.FPDF_FS_MATRIX{
-				A: float32(meta.ImageMetadata.Width) / 2.7,
-				D: float32(meta.ImageMetadata.Height) / 2.7,
-			},
-		}); err != nil {
-			p.BuildError = err
-			return
+		p.instance.FPDF_CloseXObject(&requests.FPDF_CloseXObject{XObject: xObject.XObject})
+		if err != nil {
+			return err
 		}
 
-		if _, err = p.instance.FPDFPage_InsertObject(&requests.FPDFPage_InsertObject{
-			Page: requests.Page{
-				ByReference: &newPage.Page,
-			},
-			PageObject: newImage.PageObject,
+		if _, err := p.instance.FPDFPageObj_Transform(&requests.FPDFPageObj_Transform{
+			PageObject: form.PageObject,
+			Transform:  structs.FPDF_FS_MATRIX{A: 1, D: 1, E: float32(sp.offset) - sp.box.Left, F: -sp.box.Bottom},
 		}); err != nil {
-			p.BuildError = err
-			return
+			return err
 		}
-		if _, err := p.instance.FPDFPage_GenerateContent(&requests.FPDFPage_GenerateContent{
-			Page: requests.Page{
-				ByReference: &newPage.Page,
-			},
+		if _, err := p.instance.FPDFPage_InsertObject(&requests.FPDFPage_InsertObject{
+			Page:       destinationPage,
+			PageObject: form.PageObject,
 		}); err != nil {
-			p.BuildError = err
-			return
+			return err
 		}
+	}
 
-		if _, err = p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: newPage.Page}); err != nil {
-			p.BuildError = err
-			return
+	_, err = p.instance.FPDFPage_GenerateContent(&requests.FPDFPage_GenerateContent{Page: destinationPage})
+	return err
+}
+
+// copyImageData copies the pixels of an image object. JPEGs are copied as they are, without re-encoding.
+// Anything else, such as JPX or Flate images, is decoded by pdfium and stored afresh.
+func (p *PdfBuilder) copyImageData(from, to references.FPDF_PAGEOBJECT, page requests.Page) error {
+	if slices.Equal(imageFilters(p.instance, from), []string{"DCTDecode"}) {
+		raw, err := p.instance.FPDFImageObj_GetImageDataRaw(&requests.FPDFImageObj_GetImageDataRaw{
+			ImageObject: from,
+		})
+		if err != nil {
+			return err
 		}
+		_, err = p.instance.FPDFImageObj_LoadJpegFileInline(&requests.FPDFImageObj_LoadJpegFileInline{
+			Page:        &page,
+			ImageObject: to,
+			FileData:    raw.Data,
+		})
+		return err
+	}
+
+	bitmap, err := p.instance.FPDFImageObj_GetBitmap(&requests.FPDFImageObj_GetBitmap{ImageObject: from})
+	if err != nil {
+		return err
+	}
+	defer p.instance.FPDFBitmap_Destroy(&requests.FPDFBitmap_Destroy{Bitmap: bitmap.Bitmap})
+
+	_, err = p.instance.FPDFImageObj_SetBitmap(&requests.FPDFImageObj_SetBitmap{
+		Page:        &page,
+		ImageObject: to,
+		Bitmap:      bitmap.Bitmap,
+	})
+	return err
+}
 
+// CopyPages imports the pages of an episode as they are. The pages are imported together so that the
+// resources they share, such as fonts, are only copied once. Spreads are then joined in front of their
+// halves, which are deleted unless they're to be kept.
+func (p *PdfBuilder) CopyPages(episode api.ExportPage, insertIndex int, progress *buildProgress) (int, error) {
+	source, closeSource, err := p.loadSource(episode.Filename)
+	if err != nil {
+		return 0, err
+	}
+	defer closeSource()
+
+	pages := p.filterPages(episode, source)
+	if len(pages) == 0 {
+		return 0, nil
+	}
+	pageRange := pageRanges(pages)
+	if _, err := p.instance.FPDF_ImportPages(&requests.FPDF_ImportPages{
+		Source:      source,
+		Destination: p.destination,
+		PageRange:   &pageRange,
+		Index:       insertIndex,
+	}); err != nil {
+		return 0, &api.PageError{Filename: episode.Filename, Page: pages[0], Err: err}
+	}
+
+	// The imported pages are in the same order as the layout, less the joined spreads, so each spread is
+	// inserted in front of its halves as they're reached
+	layout := p.layoutPages(episode, source, pages)
+	for i, entry := range layout {
+		if len(entry) == 1 {
+			continue
+		}
+		if err := p.joinPages(source, entry, insertIndex+i); err != nil {
+			return i, &api.PageError{Filename: episode.Filename, Page: entry[0], Err: err}
+		}
+		if p.spreads.KeepOriginals {
+			continue
+		}
+		for range entry {
+			if _, err := p.instance.FPDFPage_Delete(&requests.FPDFPage_Delete{
+				Document:  p.destination,
+				PageIndex: insertIndex + i + 1,
+			}); err != nil {
+				return i, &api.PageError{Filename: episode.Filename, Page: entry[0], Err: err}
+			}
+		}
+	}
+
+	for i, entry := range layout {
+		if err := p.resamplePage(insertIndex + i); err != nil {
+			return len(layout), &api.PageError{Filename: episode.Filename, Page: entry[0], Err: err}
+		}
+		if err := progress.pageAdded(episode.Filename, entry[0]); err != nil {
+			return len(layout), err
+		}
+	}
+	return len(layout), nil
+}
+
+// layoutPages looks for spreads among the pages of an episode, if the builder has been asked to join them,
+// and records the ones it finds
+func (p *PdfBuilder) layoutPages(episode api.ExportPage, source references.FPDF_DOCUMENT, pages []int) [][]int {
+	layout, joined := layoutPages(p.instance, source, episode, pages, p.spreads)
+	p.joined = append(p.joined, joined...)
+	return layout
+}
+
+// CopyScrollPages adds an episode as the tall pages of a vertical scroll, each a single image stitched
+// together from the page images a CBZ export would have
+func (p *PdfBuilder) CopyScrollPages(episode api.ExportPage, insertIndex int, artistsEdition bool, progress *buildProgress) (pagesAdded int, err error) {
+	joinedBefore := len(p.images.joined)
+	strips, dropped, err := p.images.Images(episode, artistsEdition, p.filter, progress)
+	if err != nil {
+		return 0, err
+	}
+	p.dropped = append(p.dropped, dropped...)
+	p.joined = append(p.joined, p.images.joined[joinedBefore:]...)
+
+	for _, strip := range strips {
+		if err := p.insertImagePage(strip, insertIndex+pagesAdded); err != nil {
+			return pagesAdded, &api.PageError{Filename: episode.Filename, Err: err}
+		}
 		pagesAdded++
 	}
-	return
+	return pagesAdded, nil
 }
 
-func (p *PdfBuilder) shouldSkipPage(source *responses.FPDF_LoadDocument, pageIndex int) bool {
-	println(fmt.Sprintf("Checking pageIndex: %d", pageIndex))
-	ref, err := p.instance.FPDFText_LoadPage(&requests.FPDFText_LoadPage{Page: requests.Page{
-		ByIndex: &requests.PageByIndex{
-			Document: source.Document,
-			Index:    pageIndex - 1,
-		},
-	}})
+// insertImagePage adds a page filled by a single JPEG, as wide as a scroll's pages and as tall as the image
+// needs
+func (p *PdfBuilder) insertImagePage(img PageImage, index int) error {
+	width := scrollPageWidth
+	height := width * float64(img.Height) / float64(img.Width)
+	newPage, err := p.instance.FPDFPage_New(&requests.FPDFPage_New{
+		Document:  p.destination,
+		PageIndex: index,
+		Width:     width,
+		Height:    height,
+	})
+	if err != nil {
+		return err
+	}
+	defer p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: newPage.Page})
+	page := requests.Page{ByReference: &newPage.Page}
+
+	image, err := p.instance.FPDFPageObj_NewImageObj(&requests.FPDFPageObj_NewImageObj{Document: p.destination})
 	if err != nil {
-		// Bad page ref?
-		println("Could not determine if we should skip page", err.Error())
-		return false
-	}
-	if r, err := p.instance.FPDFText_GetText(&requests.FPDFText_GetText{
-		TextPage:   ref.TextPage,
-		StartIndex: 0,
-		Count:      1000,
+		return err
+	}
+	if _, err := p.instance.FPDFImageObj_LoadJpegFileInline(&requests.FPDFImageObj_LoadJpegFileInline{
+		Page:        &page,
+		ImageObject: image.PageObject,
+		FileData:    img.Data,
 	}); err != nil {
-		println("No text found on page to check for skipping")
-		return false
-	} else {
-		re := regexp.MustCompile("on sale \\d{1,2} \\w+ \\d{4}")
-		return strings.Contains(strings.ToLower(r.Text), "on sale now") || re.MatchString(strings.ToLower(r.Text))
+		return err
+	}
+	if _, err := p.instance.FPDFPageObj_SetMatrix(&requests.FPDFPageObj_SetMatrix{
+		PageObject: image.PageObject,
+		Transform:  structs.FPDF_FS_MATRIX{A: float32(width), D: float32(height)},
+	}); err != nil {
+		return err
 	}
+	if _, err := p.instance.FPDFPage_InsertObject(&requests.FPDFPage_InsertObject{
+		Page:       page,
+		PageObject: image.PageObject,
+	}); err != nil {
+		return err
+	}
+	_, err = p.instance.FPDFPage_GenerateContent(&requests.FPDFPage_GenerateContent{Page: page})
+	return err
 }
 
-func (p *PdfBuilder) CopyPages(sourceFile *string, pageFrom, pageTo, insertIndex int) int {
-	if p.BuildError != nil {
-		println("Cannot copy pages", p.BuildError)
-		return 0
+// resamplePage applies the builder's export profile to the images on a page of the document, including
+// those inside form objects, such as the halves of a joined spread
+func (p *PdfBuilder) resamplePage(index int) error {
+	if p.profile.IsOriginal() {
+		return nil
 	}
-	var source *responses.FPDF_LoadDocument
-	if source, p.BuildError = p.instance.FPDF_LoadDocument(&requests.FPDF_LoadDocument{
-		Path: sourceFile,
-	}); p.BuildError != nil {
-		return 0
+	ref, err := p.instance.FPDF_LoadPage(&requests.FPDF_LoadPage{
+		Document: p.destination,
+		Index:    index,
+	})
+	if err != nil {
+		return err
 	}
+	defer p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: ref.Page})
+	page := requests.Page{ByReference: &ref.Page}
 
-	// Sometimes the tail end of an episode has adverts. We can try and filter them out
-	for pageIndex := pageTo; pageIndex > pageFrom; pageIndex-- {
-		if p.shouldSkipPage(source, pageIndex) {
-			println(fmt.Sprintf("Skipping page %d", pageIndex))
-			pageTo--
-			continue
+	images, err := pageImageObjects(p.instance, ref.Page)
+	if err != nil {
+		return err
+	}
+	forms, err := pageFormObjects(p.instance, ref.Page)
+	if err != nil {
+		return err
+	}
+	for _, form := range forms {
+		formImages, err := formImageObjects(p.instance, form)
+		if err != nil {
+			return err
 		}
+		images = append(images, formImages...)
+	}
+	for _, image := range images {
+		if err := resampleImageObject(p.instance, page, image, p.profile, &p.savings); err != nil {
+			return fmt.Errorf("resampling: %w", err)
+		}
+	}
+	_, err = p.instance.FPDFPage_GenerateContent(&requests.FPDFPage_GenerateContent{Page: page})
+	return err
+}
 
-		// If we didn't continue then assume we're into episode pages. Conceivably, the phrase "on sale now" might
-		// be in the dialogue, so going through all the pages doesn't make sense.
-		break
+// filterPages returns the pages of the range that pass the builder's filters, recording those that don't
+func (p *PdfBuilder) filterPages(episode api.ExportPage, source references.FPDF_DOCUMENT) []int {
+	if p.filter == nil {
+		pages := make([]int, 0, episode.PageTo-episode.PageFrom+1)
+		for pageNum := episode.PageFrom; pageNum <= episode.PageTo; pageNum++ {
+			pages = append(pages, pageNum)
+		}
+		return pages
 	}
-	pageRange := fmt.Sprintf("%d-%d", pageFrom, pageTo)
-	println("Copying pages", pageRange)
-	_, p.BuildError = p.instance.FPDF_ImportPages(&requests.FPDF_ImportPages{
-		Source:      source.Document,
-		Destination: p.destination.Document,
-		PageRange:   &pageRange,
-		Index:       insertIndex,
+	kept, dropped := p.filter.Episode(p.instance, source, episode)
+	p.dropped = append(p.dropped, dropped...)
+	return kept
+}
+
+// InsertGeneratedPages adds pages of text to the document, starting at the given index
+func (p *PdfBuilder) InsertGeneratedPages(pages []generatedPage, insertIndex int, width, height float64) (pagesAdded int, err error) {
+	for _, page := range pages {
+		if err := p.insertGeneratedPage(page, insertIndex+pagesAdded, width, height); err != nil {
+			return pagesAdded, err
+		}
+		pagesAdded++
+	}
+	return pagesAdded, nil
+}
+
+func (p *PdfBuilder) insertGeneratedPage(page generatedPage, index int, width, height float64) error {
+	newPage, err := p.instance.FPDFPage_New(&requests.FPDFPage_New{
+		Document:  p.destination,
+		PageIndex: index,
+		Width:     width,
+		Height:    height,
 	})
+	if err != nil {
+		return err
+	}
+	defer p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: newPage.Page})
 
-	return pageTo - pageFrom + 1
+	for _, line := range page.Lines {
+		if err := p.insertText(newPage.Page, line, width); err != nil {
+			return err
+		}
+	}
+	_, err = p.instance.FPDFPage_GenerateContent(&requests.FPDFPage_GenerateContent{
+		Page: requests.Page{ByReference: &newPage.Page},
+	})
+	return err
 }
 
-func (p *PdfBuilder) Save(outputPath string) {
-	if p.BuildError != nil {
-		return
+func (p *PdfBuilder) insertText(page references.FPDF_PAGE, line textLine, pageWidth float64) error {
+	font := "Helvetica"
+	if line.Bold {
+		font = "Helvetica-Bold"
 	}
-	p.savedAs, p.BuildError = p.instance.FPDF_SaveAsCopy(&requests.FPDF_SaveAsCopy{
+	text, err := p.instance.FPDFPageObj_NewTextObj(&requests.FPDFPageObj_NewTextObj{
+		Document: p.destination,
+		Font:     font,
+		FontSize: float32(line.Size),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := p.instance.FPDFText_SetText(&requests.FPDFText_SetText{
+		PageObject: text.PageObject,
+		Text:       line.Text,
+	}); err != nil {
+		return err
+	}
+
+	// The text's width is only known once it's set, so alignment is done by measuring it
+	x := line.X
+	if line.Align != alignLeft {
+		bounds, err := p.instance.FPDFPageObj_GetBounds(&requests.FPDFPageObj_GetBounds{PageObject: text.PageObject})
+		if err != nil {
+			return err
+		}
+		textWidth := float64(bounds.Right - bounds.Left)
+		if line.Align == alignCentre {
+			x = (pageWidth - textWidth) / 2
+		} else {
+			x = line.X - textWidth
+		}
+	}
+
+	if _, err := p.instance.FPDFPageObj_Transform(&requests.FPDFPageObj_Transform{
+		PageObject: text.PageObject,
+		Transform:  structs.FPDF_FS_MATRIX{A: 1, D: 1, E: float32(x), F: float32(line.Y)},
+	}); err != nil {
+		return err
+	}
+	_, err = p.instance.FPDFPage_InsertObject(&requests.FPDFPage_InsertObject{
+		Page:       requests.Page{ByReference: &page},
+		PageObject: text.PageObject,
+	})
+	return err
+}
+
+// pageSize returns the size of a page in the destination document
+func (p *PdfBuilder) pageSize(index int) (width, height float64, err error) {
+	size, err := p.instance.FPDF_GetPageSizeByIndex(&requests.FPDF_GetPageSizeByIndex{
+		Document: p.destination,
+		Index:    index,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return size.Width, size.Height, nil
+}
+
+func (p *PdfBuilder) Save(outputPath string) error {
+	_, err := p.instance.FPDF_SaveAsCopy(&requests.FPDF_SaveAsCopy{
 		Flags:    requests.SaveFlagIncremental,
-		Document: p.destination.Document,
+		Document: p.destination,
 		FilePath: &outputPath,
 	})
+	return err
+}
+
+func (p *PdfBuilder) AddBookmarks(outputPath string, bookmarks []pdfcpu.Bookmark) error {
+	return pdfApi.AddBookmarksFile(outputPath, outputPath, bookmarks, true, nil)
+}
+
+func (p *PdfBuilder) AddMetadata(outputPath string, episodes []api.ExportPage, sources []api.ExportSource) error {
+	return WriteMetadata(outputPath, episodes, sources)
 }
 
-func (p *PdfBuilder) AddBookmarks(bookmarks []pdfcpu.Bookmark) {
-	if p.BuildError != nil {
-		return
+// Finish lays a saved export out for printing, if asked to, and then adds its bookmarks and metadata.
+// Imposing writes a new document, so it has to come first for the metadata to survive, and the bookmarks
+// are left out of a print layout as its sheets no longer have a page for each one.
+func (p *PdfBuilder) Finish(outputPath string, outline []pdfcpu.Bookmark, episodes []api.ExportPage, sources []api.ExportSource, print api.PrintOptions) error {
+	if print.Layout != api.PrintPages {
+		if err := impose(outputPath, print); err != nil {
+			return err
+		}
+	} else if err := p.AddBookmarks(outputPath, outline); err != nil {
+		return err
 	}
-	p.BuildError = pdfApi.AddBookmarksFile(*p.savedAs.FilePath, *p.savedAs.FilePath, bookmarks, true, nil)
+	return p.AddMetadata(outputPath, episodes, sources)
 }
 
-func (p *PdfBuilder) Build(episodes []api.ExportPage, artistsEdition bool, outputPath string) (buildError error) {
-	p.OpenDestination()
+// Build exports the episodes as a PDF. If the build fails or is cancelled, nothing is left at the output
+// path.
+func (p *PdfBuilder) Build(ctx context.Context, episodes []api.ExportPage, options api.BuildOptions, outputPath string) (report api.BuildReport, buildError error) {
+	logger := logr.FromContextOrDiscard(ctx)
+	progress := newBuildProgress(ctx, options, len(episodes))
+
+	filter, err := newBuildFilter(logger, options)
+	if err != nil {
+		return report, err
+	}
+	p.filter = filter
+	if options.Print.Layout != api.PrintPages {
+		if options.Scroll.Enabled {
+			return report, errors.New("a vertical scroll can't be laid out for printing")
+		}
+		if _, err := printConfig(options.Print); err != nil {
+			return report, err
+		}
+	}
+	p.dropped = make([]api.DroppedPage, 0)
+	p.profile = options.Profile
+	p.spreads = options.Spreads
+	p.images.profile = options.Profile
+	p.images.spreads = options.Spreads
+	p.images.scroll = options.Scroll
+	p.joined = make([]api.JoinedSpread, 0)
+
+	destination, err := p.instance.FPDF_CreateNewDocument(&requests.FPDF_CreateNewDocument{})
+	if err != nil {
+		return report, err
+	}
+	p.destination = destination.Document
+	defer p.instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: p.destination})
+
+	defer func() {
+		report.Dropped = p.dropped
+		report.Savings = p.savings
+		if options.Scroll.Enabled {
+			report.Savings = p.images.savings
+		}
+		report.Spreads = p.joined
+		if buildError != nil {
+			os.Remove(outputPath)
+		}
+	}()
 
 	pageCount := 0
-	bookmarks := make([]pdfcpu.Bookmark, 0, len(episodes))
+	entries := make([]outlineEntry, 0, len(episodes))
+	sources := make([]api.ExportSource, 0, len(episodes))
 	for _, episode := range episodes {
-		pagesAdded := 0
-		if artistsEdition {
-			pagesAdded = p.CopyStrippedPages(&episode.Filename, episode.PageFrom, episode.PageTo, pageCount)
-		} else {
-			pagesAdded = p.CopyPages(&episode.Filename, episode.PageFrom, episode.PageTo, pageCount)
-		}
-		println(fmt.Sprintf("Adding %d pages", pagesAdded))
-		if len(episode.Title) > 0 {
-			println(fmt.Sprintf("Adding bookmark from %d to %d: %s", pageCount+1, pageCount+pagesAdded, episode.Title))
-			bookmarks = append(bookmarks, pdfcpu.Bookmark{
-				Title:    episode.Title,
-				PageFrom: pageCount + 1,
-				PageThru: pageCount + pagesAdded,
+		if err := progress.startEpisode(episode); err != nil {
+			return report, err
+		}
+
+		var pagesAdded int
+		switch {
+		case options.Scroll.Enabled:
+			pagesAdded, err = p.CopyScrollPages(episode, pageCount, options.ArtistsEdition, progress)
+		case options.ArtistsEdition:
+			pagesAdded, err = p.CopyStrippedPages(episode, pageCount, progress)
+		default:
+			pagesAdded, err = p.CopyPages(episode, pageCount, progress)
+		}
+		if err != nil {
+			return report, err
+		}
+		logger.Info("Added episode", "title", episode.Title, "file_name", episode.Filename, "pages", pagesAdded)
+		progress.finishEpisode()
+
+		entries = append(entries, outlineEntry{
+			page:     episode,
+			pageFrom: pageCount + 1,
+			pageThru: pageCount + pagesAdded,
+		})
+		if pagesAdded > 0 {
+			sources = append(sources, api.ExportSource{
+				Publication: episode.Publication,
+				IssueNumber: episode.IssueNumber,
+				Title:       episode.Title,
+				PageFrom:    episode.PageFrom,
+				PageTo:      episode.PageTo,
 			})
 		}
 		pageCount += pagesAdded
 	}
-	p.Save(outputPath)
-	p.AddBookmarks(bookmarks)
 
-	return p.BuildError
+	if err := progress.finishing(); err != nil {
+		return report, err
+	}
+	generated := 0
+	if pageCount > 0 && (options.TitlePage || options.ContentsPage || options.CreditsPage) {
+		front, back, err := p.addGeneratedPages(episodes, entries, pageCount, options)
+		if err != nil {
+			return report, err
+		}
+		for i := range entries {
+			entries[i].pageFrom += front
+			entries[i].pageThru += front
+		}
+		generated = front + back
+	}
+
+	if pageCount > 0 {
+		if report.BlankPages, err = p.preparePrint(pageCount+generated, options.Print); err != nil {
+			return report, err
+		}
+	}
+
+	report.Pages = pageCount + generated + report.BlankPages
+	outline := buildOutline(entries, options.ProgBookmarks)
+	if options.Print.Layout != api.PrintPages {
+		outline = nil
+	}
+	if err := p.Save(outputPath); err != nil {
+		return report, err
+	}
+	if err := p.Finish(outputPath, outline, episodes, sources, options.Print); err != nil {
+		return report, err
+	}
+
+	// The expected page count is worked out from the episodes rather than from the pages added, so that
+	// pages that went missing along the way are caught. A joined spread replaces its two halves, or is added
+	// to them if they're kept.
+	expectedPages := generated + report.BlankPages - len(p.dropped)
+	if options.Spreads.KeepOriginals {
+		expectedPages += len(p.joined)
+	} else {
+		expectedPages -= len(p.joined)
+	}
+	for _, episode := range episodes {
+		expectedPages += episode.PageTo - episode.PageFrom + 1
+	}
+	if options.Scroll.Enabled {
+		// The pages of a scroll are strips, whose number depends on the images they were stitched from, so
+		// there's nothing to check them against
+		expectedPages = 0
+	}
+	if options.Print.Layout != api.PrintPages {
+		// Each side of a sheet holds two pages, and the padding added for the layout fills the last one
+		expectedPages /= 2
+	}
+	validation := ValidateExport(outputPath, expectedPages, outline)
+	for _, problem := range validation.Problems {
+		logger.Info("Export failed validation", "file_name", outputPath, "check", problem.Check, "problem", problem.Message)
+	}
+	report.Validation = []api.ValidationReport{validation}
+	return report, nil
+}
+
+// preparePrint pads the document with blank pages to fill the last sheet of its print layout, and adds crop
+// marks to every page if asked to. It returns the number of blank pages added.
+func (p *PdfBuilder) preparePrint(pageCount int, options api.PrintOptions) (int, error) {
+	blank := printPadding(pageCount, options.Layout)
+	if blank > 0 {
+		width, height, err := p.pageSize(0)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := p.InsertGeneratedPages(make([]generatedPage, blank), pageCount, width, height); err != nil {
+			return 0, err
+		}
+	}
+	if options.CropMarks {
+		for i := 0; i < pageCount+blank; i++ {
+			if err := p.addCropMarks(i); err != nil {
+				return blank, fmt.Errorf("adding crop marks to page %d: %w", i+1, err)
+			}
+		}
+	}
+	return blank, nil
+}
+
+// addGeneratedPages adds the title and contents pages to the front of the document, and the credits to the
+// end, returning the number of pages added to each
+func (p *PdfBuilder) addGeneratedPages(episodes []api.ExportPage, entries []outlineEntry, pageCount int, options api.BuildOptions) (front, back int, err error) {
+	// Generated pages match the size of the first story page, or its width in a scroll, whose pages are far
+	// too tall for them
+	width, height, err := p.pageSize(0)
+	if err != nil {
+		return 0, 0, err
+	}
+	if options.Scroll.Enabled {
+		height = width * 1.5
+	}
+
+	if options.CreditsPage {
+		if back, err = p.InsertGeneratedPages(creditsPages(episodes, width, height), pageCount, width, height); err != nil {
+			return 0, back, err
+		}
+	}
+
+	frontPages := make([]generatedPage, 0)
+	if options.TitlePage {
+		frontPages = append(frontPages, titlePage(episodes, width, height))
+	}
+	if options.ContentsPage {
+		offset := len(frontPages) + countContentsPages(entries, width, height)
+		frontPages = append(frontPages, contentsPages(entries, offset, width, height)...)
+	}
+	front, err = p.InsertGeneratedPages(frontPages, 0, width, height)
+	return front, back, err
+}
+
+// newBuildFilter compiles the page filters for a build, falling back to the defaults
+func newBuildFilter(logger logr.Logger, options api.BuildOptions) (*PageFilter, error) {
+	rules := options.PageFilters
+	if rules == nil {
+		rules = api.DefaultPageFilters()
+	}
+	filter, err := NewPageFilter(rules)
+	if err != nil {
+		return nil, err
+	}
+	filter.log = logger
+	filter.overrides = options.PageOverrides
+	return filter, nil
 }
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyImageData_Bitmap(t *testing.T) {
+	IntegrationTest(t)
+	p := NewPdfBuilder()
+
+	doc, err := p.instance.FPDF_CreateNewDocument(&requests.FPDF_CreateNewDocument{})
+	require.NoError(t, err)
+	defer p.instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: doc.Document})
+	p.destination = doc.Document
+
+	newPage, err := p.instance.FPDFPage_New(&requests.FPDFPage_New{Document: doc.Document, Width: 100, Height: 100})
+	require.NoError(t, err)
+	defer p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: newPage.Page})
+	page := requests.Page{ByReference: &newPage.Page}
+
+	// An image set from a bitmap is stored with Flate rather than as a JPEG, so has to be decoded to copy it
+	bitmap, err := p.instance.FPDFBitmap_Create(&requests.FPDFBitmap_Create{Width: 3, Height: 2})
+	require.NoError(t, err)
+	defer p.instance.FPDFBitmap_Destroy(&requests.FPDFBitmap_Destroy{Bitmap: bitmap.Bitmap})
+	_, err = p.instance.FPDFBitmap_FillRect(&requests.FPDFBitmap_FillRect{
+		Bitmap: bitmap.Bitmap, Width: 3, Height: 2, Color: 0xff336699,
+	})
+	require.NoError(t, err)
+
+	source, err := p.instance.FPDFPageObj_NewImageObj(&requests.FPDFPageObj_NewImageObj{Document: doc.Document})
+	require.NoError(t, err)
+	_, err = p.instance.FPDFImageObj_SetBitmap(&requests.FPDFImageObj_SetBitmap{
+		Page: &page, ImageObject: source.PageObject, Bitmap: bitmap.Bitmap,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, []string{"DCTDecode"}, imageFilters(p.instance, source.PageObject))
+
+	copied, err := p.instance.FPDFPageObj_NewImageObj(&requests.FPDFPageObj_NewImageObj{Document: doc.Document})
+	require.NoError(t, err)
+	require.NoError(t, p.copyImageData(source.PageObject, copied.PageObject, page))
+
+	copiedBitmap, err := p.instance.FPDFImageObj_GetBitmap(&requests.FPDFImageObj_GetBitmap{ImageObject: copied.PageObject})
+	require.NoError(t, err)
+	defer p.instance.FPDFBitmap_Destroy(&requests.FPDFBitmap_Destroy{Bitmap: copiedBitmap.Bitmap})
+	img, err := bitmapImage(p.instance, copiedBitmap.Bitmap)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, img.Bounds().Dx())
+	assert.Equal(t, 2, img.Bounds().Dy())
+	r, g, b, _ := img.At(2, 1).RGBA()
+	assert.Equal(t, color.RGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xff}, color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0xff})
+}
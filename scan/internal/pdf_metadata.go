@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/chooban/progger/scan/api"
+	pdfApi "github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// sourcesProperty is the custom Info dictionary entry holding the JSON list of an export's source issues
+const sourcesProperty = "ProggerSources"
+
+// pdfMetadata is the document metadata written to exported PDFs, both as the Info dictionary and as XMP
+type pdfMetadata struct {
+	Title       string
+	Authors     []string
+	Subject     string
+	Keywords    []string
+	Publication string
+	Issues      string
+	Sources     []api.ExportSource
+	Modified    string
+}
+
+func newPdfMetadata(pages []api.ExportPage, sources []api.ExportSource) pdfMetadata {
+	m := newExportMetadata(pages)
+
+	keywords := make([]string, 0)
+	keywords = append(keywords, m.Series...)
+	keywords = append(keywords, m.Publications...)
+	for _, s := range m.Stories {
+		if !strings.EqualFold(s, m.SeriesTitle()) {
+			keywords = append(keywords, s)
+		}
+	}
+
+	return pdfMetadata{
+		Title:       m.DisplayTitle(),
+		Authors:     m.Creators(api.Script, api.Art, api.Colours, api.Letters),
+		Subject:     m.Summary(),
+		Keywords:    keywords,
+		Publication: strings.Join(m.Publications, ", "),
+		Issues:      m.IssueRange(),
+		Sources:     sources,
+		Modified:    time.Now().Format(time.RFC3339),
+	}
+}
+
+func (m pdfMetadata) info() (map[string]string, error) {
+	sources, err := json.Marshal(m.Sources)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"Title":         m.Title,
+		"Author":        strings.Join(m.Authors, ", "),
+		"Subject":       m.Subject,
+		"Keywords":      strings.Join(m.Keywords, ", "),
+		"Creator":       "Progger",
+		sourcesProperty: string(sources),
+	}, nil
+}
+
+func (m pdfMetadata) xmp() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := xmpTemplate.Execute(&buf, m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteMetadata sets the Info dictionary and XMP metadata of a PDF, rewriting the file in place
+func WriteMetadata(filename string, pages []api.ExportPage, sources []api.ExportSource) error {
+	metadata := newPdfMetadata(pages, sources)
+	info, err := metadata.info()
+	if err != nil {
+		return err
+	}
+	xmp, err := metadata.xmp()
+	if err != nil {
+		return err
+	}
+
+	ctx, err := pdfApi.ReadContextFile(filename)
+	if err != nil {
+		return err
+	}
+
+	// Adding no properties makes sure there is an Info dictionary, which we then fill in ourselves so that
+	// the values are escaped properly.
+	if err := pdfcpu.PropertiesAdd(ctx, nil); err != nil {
+		return err
+	}
+	if ctx.Info == nil {
+		return errors.New("pdf has no info dictionary")
+	}
+	d, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil {
+		return err
+	}
+	for k, v := range info {
+		if v == "" {
+			continue
+		}
+		s, err := pdfTextString(v)
+		if err != nil {
+			return err
+		}
+		d[k] = s
+	}
+
+	sd := types.StreamDict{Dict: types.NewDict(), Content: xmp}
+	sd.InsertName("Type", "Metadata")
+	sd.InsertName("Subtype", "XML")
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	ref, err := ctx.IndRefForNewObject(sd)
+	if err != nil {
+		return err
+	}
+	root, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	root["Metadata"] = *ref
+
+	tmpFile := filename + ".tmp"
+	if err := pdfApi.WriteContextFile(ctx, tmpFile); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+// ReadSources returns the source issues recorded in a PDF exported by Progger
+func ReadSources(filename string) ([]api.ExportSource, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	properties, err := pdfApi.Properties(f, nil)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]api.ExportSource, 0)
+	if v, ok := properties[sourcesProperty]; ok {
+		if err := json.Unmarshal([]byte(v), &sources); err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// pdfTextString encodes a string for the Info dictionary, using UTF-16 when it isn't plain ASCII
+func pdfTextString(s string) (types.StringLiteral, error) {
+	ascii := true
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			ascii = false
+			break
+		}
+	}
+
+	var escaped *string
+	var err error
+	if ascii {
+		escaped, err = types.Escape(s)
+	} else {
+		escaped, err = types.EscapeUTF16String(s)
+	}
+	if err != nil {
+		return "", err
+	}
+	return types.StringLiteral(*escaped), nil
+}
+
+var xmpTemplate = template.Must(template.New("xmp").Funcs(template.FuncMap{"x": escapeXml, "join": strings.Join}).Parse(`<?xpacket begin="` + "\uFEFF" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+  <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <rdf:Description rdf:about=""
+        xmlns:dc="http://purl.org/dc/elements/1.1/"
+        xmlns:pdf="http://ns.adobe.com/pdf/1.3/"
+        xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+        xmlns:progger="https://github.com/chooban/progger/ns/1.0/">
+      <dc:format>application/pdf</dc:format>
+      <dc:title><rdf:Alt><rdf:li xml:lang="x-default">{{ x .Title }}</rdf:li></rdf:Alt></dc:title>
+      <dc:description><rdf:Alt><rdf:li xml:lang="x-default">{{ x .Subject }}</rdf:li></rdf:Alt></dc:description>
+      <dc:creator><rdf:Seq>
+        {{- range .Authors }}
+        <rdf:li>{{ x . }}</rdf:li>
+        {{- end }}
+      </rdf:Seq></dc:creator>
+      <dc:subject><rdf:Bag>
+        {{- range .Keywords }}
+        <rdf:li>{{ x . }}</rdf:li>
+        {{- end }}
+      </rdf:Bag></dc:subject>
+      <dc:publisher><rdf:Bag><rdf:li>Rebellion</rdf:li></rdf:Bag></dc:publisher>
+      <pdf:Keywords>{{ x (join .Keywords ", ") }}</pdf:Keywords>
+      <xmp:CreatorTool>Progger</xmp:CreatorTool>
+      <xmp:ModifyDate>{{ .Modified }}</xmp:ModifyDate>
+      <progger:publication>{{ x .Publication }}</progger:publication>
+      <progger:issues>{{ x .Issues }}</progger:issues>
+      <progger:sources><rdf:Seq>
+        {{- range .Sources }}
+        <rdf:li rdf:parseType="Resource">
+          <progger:publication>{{ x .Publication }}</progger:publication>
+          <progger:issueNumber>{{ .IssueNumber }}</progger:issueNumber>
+          <progger:title>{{ x .Title }}</progger:title>
+          <progger:pageFrom>{{ .PageFrom }}</progger:pageFrom>
+          <progger:pageTo>{{ .PageTo }}</progger:pageTo>
+        </rdf:li>
+        {{- end }}
+      </rdf:Seq></progger:sources>
+    </rdf:Description>
+  </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`))
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPdfMetadata(t *testing.T) {
+	t.Parallel()
+	pages := []api.ExportPage{
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2301,
+			Series:      "Judge Dredd",
+			Story:       "Get Sin",
+			Credits:     api.Credits{api.Script: {"John Wagner"}, api.Art: {"Dan Cornwell"}},
+		},
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2302,
+			Series:      "Judge Dredd",
+			Story:       "Get Sin",
+			Credits:     api.Credits{api.Script: {"John Wagner"}, api.Letters: {"Annie Parkhouse"}},
+		},
+	}
+	sources := []api.ExportSource{
+		{Publication: "2000 AD", IssueNumber: 2301, Title: "Get Sin - Part 1", PageFrom: 3, PageTo: 8},
+		{Publication: "2000 AD", IssueNumber: 2302, Title: "Get Sin - Part 2", PageFrom: 3, PageTo: 9},
+	}
+
+	m := newPdfMetadata(pages, sources)
+	info, err := m.info()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Judge Dredd - Get Sin", info["Title"])
+	assert.Equal(t, "John Wagner, Dan Cornwell, Annie Parkhouse", info["Author"])
+	assert.Equal(t, "Judge Dredd: Get Sin, from 2000 AD Progs 2301-2302", info["Subject"])
+	assert.Equal(t, "Judge Dredd, 2000 AD, Get Sin", info["Keywords"])
+	assert.JSONEq(t, `[
+		{"Publication": "2000 AD", "IssueNumber": 2301, "Title": "Get Sin - Part 1", "PageFrom": 3, "PageTo": 8},
+		{"Publication": "2000 AD", "IssueNumber": 2302, "Title": "Get Sin - Part 2", "PageFrom": 3, "PageTo": 9}
+	]`, info[sourcesProperty])
+}
+
+func TestPdfMetadata_Xmp(t *testing.T) {
+	t.Parallel()
+	m := newPdfMetadata(
+		[]api.ExportPage{{Publication: "2000 AD", IssueNumber: 2301, Series: "Brink", Story: "Hate & Box"}},
+		[]api.ExportSource{{Publication: "2000 AD", IssueNumber: 2301, PageFrom: 9, PageTo: 14}},
+	)
+
+	data, err := m.xmp()
+	assert.NoError(t, err)
+
+	var xmp struct {
+		Title   string `xml:"RDF>Description>title>Alt>li"`
+		Sources []struct {
+			IssueNumber int `xml:"issueNumber"`
+			PageFrom    int `xml:"pageFrom"`
+		} `xml:"RDF>Description>sources>Seq>li"`
+	}
+	assert.NoError(t, xml.Unmarshal(data, &xmp))
+	assert.Equal(t, "Brink - Hate & Box", xmp.Title)
+	assert.Len(t, xmp.Sources, 1)
+	assert.Equal(t, 2301, xmp.Sources[0].IssueNumber)
+	assert.Equal(t, 9, xmp.Sources[0].PageFrom)
+}
+
+func TestPdfTextString(t *testing.T) {
+	t.Parallel()
+	testCases := []string{
+		"Judge Dredd - Get Sin",
+		"Judge Dredd (Reprint) \\ Classic",
+		"Tharg’s Future Shocks",
+	}
+	for _, tc := range testCases {
+		t.Run(tc, func(t *testing.T) {
+			t.Parallel()
+			s, err := pdfTextString(tc)
+			assert.NoError(t, err)
+
+			decoded, err := types.StringLiteralToString(s)
+			assert.NoError(t, err)
+			assert.Equal(t, tc, decoded)
+		})
+	}
+}
@@ -188,3 +188,35 @@ func (p *Reader) findScriptRect(pageRef references.FPDF_PAGE) (*responses.FPDFTe
 	}
 	return textPage, scriptRect
 }
+
+// PageText returns the plain text of each page in the given, one-indexed, inclusive range.
+func (p *Reader) PageText(filename string, startPage int, endPage int) ([]string, error) {
+	source, err := p.Instance.FPDF_LoadDocument(&requests.FPDF_LoadDocument{
+		Path: &filename,
+	})
+	if err != nil {
+		p.Log.Error(err, "Could not open file")
+		return nil, err
+	}
+	defer p.Instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: source.Document})
+
+	pages := make([]string, 0, endPage-startPage+1)
+	for pageIndex := startPage; pageIndex <= endPage; pageIndex++ {
+		text, err := p.Instance.GetPageText(&requests.GetPageText{
+			Page: requests.Page{
+				ByIndex: &requests.PageByIndex{
+					Document: source.Document,
+					Index:    pageIndex - 1,
+				},
+			},
+		})
+		if err != nil {
+			p.Log.V(1).Info(fmt.Sprintf("Failed to read text from page %d", pageIndex), "file_name", filename)
+			pages = append(pages, "")
+			continue
+		}
+		pages = append(pages, text.Text)
+	}
+
+	return pages, nil
+}
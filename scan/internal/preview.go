@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/go-logr/logr"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+// thumbnailDPI renders a prog page about 200 pixels tall
+const thumbnailDPI = 18
+
+// Preview returns every page in the episodes' ranges, marking those the page filters and overrides would
+// leave out, with a thumbnail of each. Nothing is built, so it is quick enough to show before an export.
+func Preview(ctx context.Context, episodes []api.ExportPage, options api.BuildOptions) ([]api.PreviewPage, error) {
+	progress := newBuildProgress(ctx, options, len(episodes))
+	filter, err := newBuildFilter(logr.FromContextOrDiscard(ctx), options)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := make([]api.PreviewPage, 0)
+	for _, episode := range episodes {
+		if err := progress.startEpisode(episode); err != nil {
+			return nil, err
+		}
+		pages, err := previewEpisode(episode, filter, progress)
+		if err != nil {
+			return nil, err
+		}
+		preview = append(preview, pages...)
+		progress.finishEpisode()
+	}
+	return preview, nil
+}
+
+func previewEpisode(episode api.ExportPage, filter *PageFilter, progress *buildProgress) ([]api.PreviewPage, error) {
+	source, err := Instance.FPDF_LoadDocument(&requests.FPDF_LoadDocument{
+		Path: &episode.Filename,
+	})
+	if err != nil {
+		return nil, &api.PageError{Filename: episode.Filename, Err: err}
+	}
+	defer Instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: source.Document})
+
+	_, dropped := filter.Episode(Instance, source.Document, episode)
+	pages := previewPages(episode, dropped)
+	for i := range pages {
+		if pages[i].Thumbnail, err = thumbnail(source.Document, pages[i].Page); err != nil {
+			return nil, &api.PageError{Filename: episode.Filename, Page: pages[i].Page, Err: err}
+		}
+		if err := progress.pageAdded(episode.Filename, pages[i].Page); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+// previewPages lists the pages of an episode, marking those that were dropped
+func previewPages(episode api.ExportPage, dropped []api.DroppedPage) []api.PreviewPage {
+	pages := make([]api.PreviewPage, 0, episode.PageTo-episode.PageFrom+1)
+	for pageNum := episode.PageFrom; pageNum <= episode.PageTo; pageNum++ {
+		page := api.PreviewPage{
+			Filename:    episode.Filename,
+			IssueNumber: episode.IssueNumber,
+			Page:        pageNum,
+			Episode:     episode.Title,
+		}
+		for _, d := range dropped {
+			if d.Page == pageNum {
+				page.Dropped = true
+				page.Reason = d.Reason
+			}
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// thumbnail renders a page small, as a JPEG
+func thumbnail(document references.FPDF_DOCUMENT, pageNum int) ([]byte, error) {
+	rendered, err := Instance.RenderPageInDPI(&requests.RenderPageInDPI{
+		Page: requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: document,
+				Index:    pageNum - 1,
+			},
+		},
+		DPI: thumbnailDPI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rendered.CleanupFunc != nil {
+		defer rendered.CleanupFunc()
+	}
+	img, err := encodeJpeg(rendered.Result.Image)
+	if err != nil {
+		return nil, err
+	}
+	return img.Data, nil
+}
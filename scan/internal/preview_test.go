@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewPages(t *testing.T) {
+	t.Parallel()
+	episode := api.ExportPage{Filename: "2000AD 2300.pdf", IssueNumber: 2300, Title: "Brink - Part 1", PageFrom: 3, PageTo: 5}
+	dropped := []api.DroppedPage{{Filename: "2000AD 2300.pdf", IssueNumber: 2300, Page: 5, Reason: "text matched \"on sale now\""}}
+
+	assert.Equal(t, []api.PreviewPage{
+		{Filename: "2000AD 2300.pdf", IssueNumber: 2300, Page: 3, Episode: "Brink - Part 1"},
+		{Filename: "2000AD 2300.pdf", IssueNumber: 2300, Page: 4, Episode: "Brink - Part 1"},
+		{Filename: "2000AD 2300.pdf", IssueNumber: 2300, Page: 5, Episode: "Brink - Part 1", Dropped: true, Reason: "text matched \"on sale now\""},
+	}, previewPages(episode, dropped))
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/klippa-app/go-pdfium/enums"
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/klippa-app/go-pdfium/structs"
+	pdfApi "github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+const (
+	defaultPaperSize = "A3"
+	// cropMarkMargin is the space, in points, added around each page to hold its crop marks
+	cropMarkMargin = 24
+	// cropMarkGap keeps the marks clear of the trimmed page, so that a slightly wayward cut doesn't leave
+	// them showing
+	cropMarkGap    = 6
+	cropMarkLength = 16
+	cropMarkWidth  = 0.5
+)
+
+// printPadding is the number of blank pages needed to fill the last sheet of a print layout. A booklet's
+// sheets each hold four pages, two to a side.
+func printPadding(pageCount int, layout api.PrintLayout) int {
+	perSheet := 1
+	switch layout {
+	case api.PrintBooklet:
+		perSheet = 4
+	case api.PrintTwoUp:
+		perSheet = 2
+	}
+	return (perSheet - pageCount%perSheet) % perSheet
+}
+
+// markLine is a straight line, in page coordinates
+type markLine struct {
+	x1, y1, x2, y2 float32
+}
+
+// cropMarkLines returns the marks for trimming a page to the given box: a horizontal and a vertical line
+// pointing away from each corner, outside the box
+func cropMarkLines(left, bottom, right, top float32) []markLine {
+	lines := make([]markLine, 0, 8)
+	for _, x := range []struct{ at, out float32 }{{left, -1}, {right, 1}} {
+		for _, y := range []struct{ at, out float32 }{{bottom, -1}, {top, 1}} {
+			lines = append(lines,
+				markLine{x.at + x.out*cropMarkGap, y.at, x.at + x.out*(cropMarkGap+cropMarkLength), y.at},
+				markLine{x.at, y.at + y.out*cropMarkGap, x.at, y.at + y.out*(cropMarkGap+cropMarkLength)},
+			)
+		}
+	}
+	return lines
+}
+
+// printConfig returns pdfcpu's configuration for imposing pages two to a landscape sheet
+func printConfig(options api.PrintOptions) (*model.NUp, error) {
+	desc := fmt.Sprintf("formsize:%sL, margin:0, border:off", cmp.Or(options.PaperSize, defaultPaperSize))
+	if options.Layout == api.PrintBooklet {
+		return pdfApi.PDFBookletConfig(2, desc, nil)
+	}
+	return pdfApi.PDFNUpConfig(2, desc, nil)
+}
+
+// impose lays the pages of a finished PDF out on printed sheets, replacing the file with the sheets
+func impose(filename string, options api.PrintOptions) error {
+	nup, err := printConfig(options)
+	if err != nil {
+		return err
+	}
+
+	imposed := filename + ".imposed"
+	if options.Layout == api.PrintBooklet {
+		err = pdfApi.BookletFile([]string{filename}, imposed, nil, nup, nil)
+	} else {
+		err = pdfApi.NUpFile([]string{filename}, imposed, nil, nup, nil)
+	}
+	if err != nil {
+		os.Remove(imposed)
+		return fmt.Errorf("imposing pages: %w", err)
+	}
+	return os.Rename(imposed, filename)
+}
+
+// addCropMarks grows a page of the document by a margin and draws crop marks in it. The page's trim box is
+// set to the page as it was.
+func (p *PdfBuilder) addCropMarks(index int) error {
+	ref, err := p.instance.FPDF_LoadPage(&requests.FPDF_LoadPage{
+		Document: p.destination,
+		Index:    index,
+	})
+	if err != nil {
+		return err
+	}
+	defer p.instance.FPDF_ClosePage(&requests.FPDF_ClosePage{Page: ref.Page})
+	page := requests.Page{ByReference: &ref.Page}
+
+	box, err := p.instance.FPDF_GetPageBoundingBox(&requests.FPDF_GetPageBoundingBox{Page: page})
+	if err != nil {
+		return err
+	}
+	trim := box.Rect
+	if _, err := p.instance.FPDFPage_SetTrimBox(&requests.FPDFPage_SetTrimBox{
+		Page: page, Left: trim.Left, Bottom: trim.Bottom, Right: trim.Right, Top: trim.Top,
+	}); err != nil {
+		return err
+	}
+	left, bottom := trim.Left-cropMarkMargin, trim.Bottom-cropMarkMargin
+	right, top := trim.Right+cropMarkMargin, trim.Top+cropMarkMargin
+	if _, err := p.instance.FPDFPage_SetMediaBox(&requests.FPDFPage_SetMediaBox{
+		Page: page, Left: left, Bottom: bottom, Right: right, Top: top,
+	}); err != nil {
+		return err
+	}
+	if _, err := p.instance.FPDFPage_SetCropBox(&requests.FPDFPage_SetCropBox{
+		Page: page, Left: left, Bottom: bottom, Right: right, Top: top,
+	}); err != nil {
+		return err
+	}
+
+	for _, line := range cropMarkLines(trim.Left, trim.Bottom, trim.Right, trim.Top) {
+		path, err := p.instance.FPDFPageObj_CreateNewPath(&requests.FPDFPageObj_CreateNewPath{X: line.x1, Y: line.y1})
+		if err != nil {
+			return err
+		}
+		if _, err := p.instance.FPDFPath_LineTo(&requests.FPDFPath_LineTo{
+			PageObject: path.PageObject, X: line.x2, Y: line.y2,
+		}); err != nil {
+			return err
+		}
+		if _, err := p.instance.FPDFPageObj_SetStrokeColor(&requests.FPDFPageObj_SetStrokeColor{
+			PageObject:  path.PageObject,
+			StrokeColor: structs.FPDF_COLOR{A: 255},
+		}); err != nil {
+			return err
+		}
+		if _, err := p.instance.FPDFPageObj_SetStrokeWidth(&requests.FPDFPageObj_SetStrokeWidth{
+			PageObject: path.PageObject, StrokeWidth: cropMarkWidth,
+		}); err != nil {
+			return err
+		}
+		if _, err := p.instance.FPDFPath_SetDrawMode(&requests.FPDFPath_SetDrawMode{
+			PageObject: path.PageObject, FillMode: enums.FPDF_FILLMODE_NONE, Stroke: true,
+		}); err != nil {
+			return err
+		}
+		if _, err := p.instance.FPDFPage_InsertObject(&requests.FPDFPage_InsertObject{
+			Page: page, PageObject: path.PageObject,
+		}); err != nil {
+			return err
+		}
+	}
+	_, err = p.instance.FPDFPage_GenerateContent(&requests.FPDFPage_GenerateContent{Page: page})
+	return err
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintPadding(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		pages    int
+		layout   api.PrintLayout
+		expected int
+	}{
+		{name: "Pages are never padded", pages: 7, layout: api.PrintPages, expected: 0},
+		{name: "Booklet filling its last sheet", pages: 16, layout: api.PrintBooklet, expected: 0},
+		{name: "Booklet one page over", pages: 17, layout: api.PrintBooklet, expected: 3},
+		{name: "Booklet one page short", pages: 23, layout: api.PrintBooklet, expected: 1},
+		{name: "2-up with an odd page", pages: 7, layout: api.PrintTwoUp, expected: 1},
+		{name: "2-up with even pages", pages: 8, layout: api.PrintTwoUp, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, printPadding(tc.pages, tc.layout))
+		})
+	}
+}
+
+func TestCropMarkLines(t *testing.T) {
+	t.Parallel()
+	lines := cropMarkLines(0, 0, 600, 800)
+
+	assert.Len(t, lines, 8)
+	assert.Contains(t, lines, markLine{-6, 0, -22, 0})
+	assert.Contains(t, lines, markLine{0, -6, 0, -22})
+	assert.Contains(t, lines, markLine{606, 800, 622, 800})
+	assert.Contains(t, lines, markLine{600, 806, 600, 822})
+	for _, l := range lines {
+		inside := func(x, y float32) bool { return x > 0 && x < 600 && y > 0 && y < 800 }
+		assert.False(t, inside(l.x1, l.y1) || inside(l.x2, l.y2), "mark %v is on the page", l)
+		assert.True(t, l.x1 >= -cropMarkMargin && l.x2 <= 600+cropMarkMargin, "mark %v is off the sheet", l)
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	t.Parallel()
+	booklet, err := printConfig(api.PrintOptions{Layout: api.PrintBooklet})
+	assert.NoError(t, err)
+	assert.True(t, booklet.IsBooklet())
+	assert.Greater(t, booklet.PageDim.Width, booklet.PageDim.Height)
+
+	twoUp, err := printConfig(api.PrintOptions{Layout: api.PrintTwoUp, PaperSize: "Tabloid"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, twoUp.N())
+
+	_, err = printConfig(api.PrintOptions{Layout: api.PrintTwoUp, PaperSize: "Napkin"})
+	assert.Error(t, err)
+}
+
+func TestFinishPrintLayout(t *testing.T) {
+	t.Parallel()
+	filename := blankPdf(t, 4)
+	outline := []pdfcpu.Bookmark{{Title: "Judge Dredd", PageFrom: 1}}
+	sources := []api.ExportSource{{Publication: "2000 AD", IssueNumber: 2301, Title: "Prog 2301", PageFrom: 1, PageTo: 4}}
+	pages := []api.ExportPage{{Filename: "2301.pdf", Series: "Judge Dredd", Story: "Hate Box", Title: "Hate Box", IssueNumber: 2301, Publication: "2000 AD", PageFrom: 1, PageTo: 4}}
+
+	p := &PdfBuilder{}
+	err := p.Finish(filename, outline, pages, sources, api.PrintOptions{Layout: api.PrintBooklet})
+	assert.NoError(t, err)
+
+	count, err := PageCount(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	found, err := ReadSources(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, sources, found)
+
+	bookmarks, err := ReadBookmarks(filename)
+	assert.NoError(t, err)
+	assert.Empty(t, bookmarks)
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"slices"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/enums"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+	"golang.org/x/image/draw"
+)
+
+// applyProfile resamples an encoded page image. The original is kept if resampling doesn't make it any
+// smaller, as long as it already meets the profile.
+func applyProfile(img PageImage, profile api.ExportProfile, savings *api.ImageSavings) (PageImage, error) {
+	if profile.IsOriginal() {
+		return img, nil
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return PageImage{}, err
+	}
+	resampled, err := resample(decoded, profile)
+	if err != nil {
+		return PageImage{}, err
+	}
+	if len(resampled.Data) >= len(img.Data) && meetsProfile(decoded.Bounds(), profile) {
+		resampled = img
+	}
+	addSavings(savings, len(img.Data), len(resampled.Data))
+	return resampled, nil
+}
+
+// resample scales an image to fit within the profile's limits, greys it if asked to, and encodes it as a
+// JPEG at the profile's quality
+func resample(img image.Image, profile api.ExportProfile) (PageImage, error) {
+	bounds := img.Bounds()
+	width, height := fitWithin(bounds.Dx(), bounds.Dy(), profile.MaxWidth, profile.MaxHeight)
+
+	var dst draw.Image
+	if profile.Greyscale {
+		dst = image.NewGray(image.Rect(0, 0, width, height))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	if width == bounds.Dx() && height == bounds.Dy() {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: profile.Quality}); err != nil {
+		return PageImage{}, err
+	}
+	return PageImage{Data: buf.Bytes(), Ext: "jpg", Width: width, Height: height}, nil
+}
+
+// meetsProfile reports whether an image can be used as it is: it fits within the profile's limits and
+// the profile doesn't ask for greyscale
+func meetsProfile(bounds image.Rectangle, profile api.ExportProfile) bool {
+	width, height := fitWithin(bounds.Dx(), bounds.Dy(), profile.MaxWidth, profile.MaxHeight)
+	return !profile.Greyscale && width == bounds.Dx() && height == bounds.Dy()
+}
+
+// fitWithin scales a size down, keeping its aspect ratio, until it fits within the limits. A limit of zero
+// means no limit.
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = min(scale, float64(maxWidth)/float64(width))
+	}
+	if maxHeight > 0 && height > maxHeight {
+		scale = min(scale, float64(maxHeight)/float64(height))
+	}
+	if scale == 1.0 {
+		return width, height
+	}
+	return max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5))
+}
+
+func addSavings(savings *api.ImageSavings, before, after int) {
+	savings.Images++
+	savings.OriginalBytes += int64(before)
+	savings.ExportedBytes += int64(after)
+}
+
+// resampleImageObject applies a profile to an image object on a page, replacing its data with a JPEG. The
+// object keeps its transform, so it still fills the same area of the page.
+func resampleImageObject(instance pdfium.Pdfium, page requests.Page, obj references.FPDF_PAGEOBJECT, profile api.ExportProfile, savings *api.ImageSavings) error {
+	raw, err := instance.FPDFImageObj_GetImageDataRaw(&requests.FPDFImageObj_GetImageDataRaw{ImageObject: obj})
+	if err != nil {
+		return err
+	}
+
+	var img image.Image
+	if slices.Equal(imageFilters(instance, obj), []string{"DCTDecode"}) {
+		if img, err = jpeg.Decode(bytes.NewReader(raw.Data)); err != nil {
+			return err
+		}
+	} else {
+		bitmap, err := instance.FPDFImageObj_GetBitmap(&requests.FPDFImageObj_GetBitmap{ImageObject: obj})
+		if err != nil {
+			return err
+		}
+		img, err = bitmapImage(instance, bitmap.Bitmap)
+		instance.FPDFBitmap_Destroy(&requests.FPDFBitmap_Destroy{Bitmap: bitmap.Bitmap})
+		if err != nil {
+			return err
+		}
+	}
+
+	resampled, err := resample(img, profile)
+	if err != nil {
+		return err
+	}
+	if len(resampled.Data) >= len(raw.Data) && meetsProfile(img.Bounds(), profile) {
+		addSavings(savings, len(raw.Data), len(raw.Data))
+		return nil
+	}
+	if _, err := instance.FPDFImageObj_LoadJpegFileInline(&requests.FPDFImageObj_LoadJpegFileInline{
+		Page:        &page,
+		ImageObject: obj,
+		FileData:    resampled.Data,
+	}); err != nil {
+		return err
+	}
+	addSavings(savings, len(raw.Data), len(resampled.Data))
+	return nil
+}
+
+// bitmapImage copies a pdfium bitmap into an image
+func bitmapImage(instance pdfium.Pdfium, bitmap references.FPDF_BITMAP) (image.Image, error) {
+	format, err := instance.FPDFBitmap_GetFormat(&requests.FPDFBitmap_GetFormat{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	width, err := instance.FPDFBitmap_GetWidth(&requests.FPDFBitmap_GetWidth{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	height, err := instance.FPDFBitmap_GetHeight(&requests.FPDFBitmap_GetHeight{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	stride, err := instance.FPDFBitmap_GetStride(&requests.FPDFBitmap_GetStride{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	buffer, err := instance.FPDFBitmap_GetBuffer(&requests.FPDFBitmap_GetBuffer{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	return decodeBitmap(buffer.Buffer, format.Format, width.Width, height.Height, stride.Stride)
+}
+
+// decodeBitmap converts the pixels of a pdfium bitmap, which are stored blue first
+func decodeBitmap(buffer []byte, format enums.FPDF_BITMAP_FORMAT, width, height, stride int) (image.Image, error) {
+	if len(buffer) < stride*height {
+		return nil, fmt.Errorf("bitmap buffer of %d bytes is too small for %dx%d", len(buffer), width, height)
+	}
+
+	switch format {
+	case enums.FPDF_BITMAP_FORMAT_GRAY:
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			copy(img.Pix[y*img.Stride:y*img.Stride+width], buffer[y*stride:])
+		}
+		return img, nil
+	case enums.FPDF_BITMAP_FORMAT_BGR, enums.FPDF_BITMAP_FORMAT_BGRX, enums.FPDF_BITMAP_FORMAT_BGRA:
+		bpp := 4
+		if format == enums.FPDF_BITMAP_FORMAT_BGR {
+			bpp = 3
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				i := y*stride + x*bpp
+				alpha := uint8(0xff)
+				if format == enums.FPDF_BITMAP_FORMAT_BGRA {
+					alpha = buffer[i+3]
+				}
+				img.SetNRGBA(x, y, color.NRGBA{R: buffer[i+2], G: buffer[i+1], B: buffer[i], A: alpha})
+			}
+		}
+		return img, nil
+	}
+	return nil, fmt.Errorf("unsupported bitmap format %d", format)
+}
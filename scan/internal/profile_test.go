@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/klippa-app/go-pdfium/enums"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFitWithin(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name                 string
+		width, height        int
+		maxWidth, maxHeight  int
+		expectedW, expectedH int
+	}{
+		{name: "No limits", width: 2000, height: 3000, expectedW: 2000, expectedH: 3000},
+		{name: "Already fits", width: 1000, height: 1500, maxWidth: 1600, maxHeight: 2400, expectedW: 1000, expectedH: 1500},
+		{name: "Limited by height", width: 2000, height: 3000, maxWidth: 1600, maxHeight: 2400, expectedW: 1600, expectedH: 2400},
+		{name: "Limited by width", width: 4000, height: 3000, maxWidth: 1600, maxHeight: 2400, expectedW: 1600, expectedH: 1200},
+		{name: "Width only", width: 3000, height: 1000, maxWidth: 1500, expectedW: 1500, expectedH: 500},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			w, h := fitWithin(tc.width, tc.height, tc.maxWidth, tc.maxHeight)
+			assert.Equal(t, tc.expectedW, w)
+			assert.Equal(t, tc.expectedH, h)
+		})
+	}
+}
+
+func noisyJpeg(t *testing.T, width, height int) PageImage {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x * y), A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}))
+	return PageImage{Data: buf.Bytes(), Ext: "jpg", Width: width, Height: height}
+}
+
+func TestApplyProfile(t *testing.T) {
+	t.Parallel()
+	original := noisyJpeg(t, 400, 600)
+
+	t.Run("Original profile", func(t *testing.T) {
+		t.Parallel()
+		var savings api.ImageSavings
+		img, err := applyProfile(original, api.ExportProfile{}, &savings)
+
+		assert.NoError(t, err)
+		assert.Equal(t, original, img)
+		assert.Equal(t, 0, savings.Images)
+	})
+
+	t.Run("Downscaled greyscale", func(t *testing.T) {
+		t.Parallel()
+		var savings api.ImageSavings
+		profile := api.ExportProfile{MaxWidth: 200, MaxHeight: 200, Quality: 70, Greyscale: true}
+		img, err := applyProfile(original, profile, &savings)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 133, img.Width)
+		assert.Equal(t, 200, img.Height)
+
+		decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+		assert.NoError(t, err)
+		assert.Equal(t, color.GrayModel, decoded.ColorModel())
+
+		saved, percent := savings.Saved()
+		assert.Equal(t, 1, savings.Images)
+		assert.Equal(t, int64(len(original.Data)-len(img.Data)), saved)
+		assert.Greater(t, percent, 50.0)
+	})
+
+	t.Run("Original kept when resampling is larger", func(t *testing.T) {
+		t.Parallel()
+		var savings api.ImageSavings
+		img, err := applyProfile(original, api.ExportProfile{Quality: 100}, &savings)
+
+		assert.NoError(t, err)
+		assert.Equal(t, original, img)
+		assert.Equal(t, 1, savings.Images)
+	})
+
+	t.Run("Oversized original resampled even when larger", func(t *testing.T) {
+		t.Parallel()
+		var savings api.ImageSavings
+		img, err := applyProfile(original, api.ExportProfile{MaxHeight: 599, Quality: 100}, &savings)
+
+		assert.NoError(t, err)
+		assert.Greater(t, len(img.Data), len(original.Data))
+		assert.Equal(t, 599, img.Height)
+	})
+
+	t.Run("Colour original greyed even when larger", func(t *testing.T) {
+		t.Parallel()
+		var savings api.ImageSavings
+		img, err := applyProfile(original, api.ExportProfile{Quality: 100, Greyscale: true}, &savings)
+
+		assert.NoError(t, err)
+		decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+		assert.NoError(t, err)
+		assert.Equal(t, color.GrayModel, decoded.ColorModel())
+	})
+}
+
+func TestDecodeBitmap(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		buffer   []byte
+		format   enums.FPDF_BITMAP_FORMAT
+		stride   int
+		expected []color.Color
+	}{
+		{
+			// Blue, green, red byte order, with two bytes of padding on each row
+			name:   "BGR",
+			format: enums.FPDF_BITMAP_FORMAT_BGR,
+			stride: 8,
+			buffer: []byte{
+				0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0xff, 0x00, 0x10, 0x20, 0x30, 0x00, 0x00,
+			},
+			expected: []color.Color{
+				color.NRGBA{R: 0xff, A: 0xff}, color.NRGBA{B: 0xff, A: 0xff},
+				color.NRGBA{G: 0xff, A: 0xff}, color.NRGBA{R: 0x30, G: 0x20, B: 0x10, A: 0xff},
+			},
+		},
+		{
+			name:   "BGRX ignores the fourth byte",
+			format: enums.FPDF_BITMAP_FORMAT_BGRX,
+			stride: 8,
+			buffer: []byte{
+				0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0x00,
+				0x00, 0xff, 0x00, 0x00, 0x10, 0x20, 0x30, 0x00,
+			},
+			expected: []color.Color{
+				color.NRGBA{R: 0xff, A: 0xff}, color.NRGBA{B: 0xff, A: 0xff},
+				color.NRGBA{G: 0xff, A: 0xff}, color.NRGBA{R: 0x30, G: 0x20, B: 0x10, A: 0xff},
+			},
+		},
+		{
+			name:   "BGRA with a padded stride",
+			format: enums.FPDF_BITMAP_FORMAT_BGRA,
+			stride: 12,
+			buffer: []byte{
+				0x00, 0x00, 0xff, 0x80, 0xff, 0x00, 0x00, 0xff, 0xee, 0xee, 0xee, 0xee,
+				0x00, 0xff, 0x00, 0x00, 0x10, 0x20, 0x30, 0x40, 0xee, 0xee, 0xee, 0xee,
+			},
+			expected: []color.Color{
+				color.NRGBA{R: 0xff, A: 0x80}, color.NRGBA{B: 0xff, A: 0xff},
+				color.NRGBA{G: 0xff, A: 0x00}, color.NRGBA{R: 0x30, G: 0x20, B: 0x10, A: 0x40},
+			},
+		},
+		{
+			name:   "Grey with a padded stride",
+			format: enums.FPDF_BITMAP_FORMAT_GRAY,
+			stride: 4,
+			buffer: []byte{
+				0x00, 0xff, 0xee, 0xee,
+				0x40, 0x80, 0xee, 0xee,
+			},
+			expected: []color.Color{
+				color.Gray{Y: 0x00}, color.Gray{Y: 0xff},
+				color.Gray{Y: 0x40}, color.Gray{Y: 0x80},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			img, err := decodeBitmap(tc.buffer, tc.format, 2, 2, tc.stride)
+			assert.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 2, 2), img.Bounds())
+			for i, expected := range tc.expected {
+				assert.Equal(t, expected, img.At(i%2, i/2), "pixel %d", i)
+			}
+
+			_, err = decodeBitmap(tc.buffer[:len(tc.buffer)-1], tc.format, 2, 2, tc.stride)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDecodeBitmap_UnsupportedFormat(t *testing.T) {
+	t.Parallel()
+	_, err := decodeBitmap(make([]byte, 4), enums.FPDF_BITMAP_FORMAT_UNKNOWN, 1, 1, 4)
+	assert.Error(t, err)
+}
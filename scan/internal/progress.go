@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/chooban/progger/scan/api"
+)
+
+// buildProgress sends progress events to the callback in a build's options, if there is one, and checks
+// whether the build has been cancelled.
+type buildProgress struct {
+	ctx      context.Context
+	report   func(api.ProgressEvent)
+	episodes int
+	episode  int
+	title    string
+	pages    int
+}
+
+func newBuildProgress(ctx context.Context, options api.BuildOptions, episodes int) *buildProgress {
+	return &buildProgress{ctx: ctx, report: options.Progress, episodes: episodes}
+}
+
+// cancelled returns the context's error once the build has been cancelled
+func (p *buildProgress) cancelled() error {
+	return p.ctx.Err()
+}
+
+func (p *buildProgress) startEpisode(episode api.ExportPage) error {
+	if err := p.cancelled(); err != nil {
+		return err
+	}
+	p.episode++
+	p.title = episode.Title
+	p.send(api.ProgressEvent{Stage: api.ProgressEpisodeStarted, Filename: episode.Filename})
+	return nil
+}
+
+// pageAdded records a page being added, and returns an error if the build has been cancelled
+func (p *buildProgress) pageAdded(filename string, page int) error {
+	p.pages++
+	p.send(api.ProgressEvent{Stage: api.ProgressPageAdded, Filename: filename, Page: page})
+	return p.cancelled()
+}
+
+func (p *buildProgress) finishEpisode() {
+	p.send(api.ProgressEvent{Stage: api.ProgressEpisodeFinished})
+}
+
+func (p *buildProgress) finishing() error {
+	if err := p.cancelled(); err != nil {
+		return err
+	}
+	p.send(api.ProgressEvent{Stage: api.ProgressFinishing})
+	return nil
+}
+
+func (p *buildProgress) send(event api.ProgressEvent) {
+	if p.report == nil {
+		return
+	}
+	event.Episode = p.episode
+	event.Episodes = p.episodes
+	event.Title = p.title
+	event.PagesAdded = p.pages
+	p.report(event)
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildProgress(t *testing.T) {
+	t.Parallel()
+	events := make([]api.ProgressEvent, 0)
+	options := api.BuildOptions{Progress: func(e api.ProgressEvent) { events = append(events, e) }}
+	progress := newBuildProgress(context.Background(), options, 2)
+
+	assert.NoError(t, progress.startEpisode(api.ExportPage{Title: "Get Sin - Part 1", Filename: "2301.pdf"}))
+	assert.NoError(t, progress.pageAdded("2301.pdf", 3))
+	assert.NoError(t, progress.pageAdded("2301.pdf", 4))
+	progress.finishEpisode()
+	assert.NoError(t, progress.startEpisode(api.ExportPage{Title: "Get Sin - Part 2", Filename: "2302.pdf"}))
+	assert.NoError(t, progress.pageAdded("2302.pdf", 5))
+	progress.finishEpisode()
+	assert.NoError(t, progress.finishing())
+
+	stages := make([]api.ProgressStage, 0, len(events))
+	for _, e := range events {
+		stages = append(stages, e.Stage)
+	}
+	assert.Equal(t, []api.ProgressStage{
+		api.ProgressEpisodeStarted,
+		api.ProgressPageAdded,
+		api.ProgressPageAdded,
+		api.ProgressEpisodeFinished,
+		api.ProgressEpisodeStarted,
+		api.ProgressPageAdded,
+		api.ProgressEpisodeFinished,
+		api.ProgressFinishing,
+	}, stages)
+
+	assert.Equal(t, api.ProgressEvent{
+		Stage:      api.ProgressPageAdded,
+		Episode:    2,
+		Episodes:   2,
+		Title:      "Get Sin - Part 2",
+		Filename:   "2302.pdf",
+		Page:       5,
+		PagesAdded: 3,
+	}, events[5])
+}
+
+func TestBuildProgress_Cancelled(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := newBuildProgress(ctx, api.BuildOptions{}, 1)
+
+	assert.NoError(t, progress.startEpisode(api.ExportPage{}))
+	cancel()
+	assert.ErrorIs(t, progress.pageAdded("2301.pdf", 1), context.Canceled)
+	assert.ErrorIs(t, progress.startEpisode(api.ExportPage{}), context.Canceled)
+	assert.ErrorIs(t, progress.finishing(), context.Canceled)
+}
+
+func TestPageError(t *testing.T) {
+	t.Parallel()
+	cause := errors.New("pdf_page_object not found")
+
+	err := fmt.Errorf("volume 1: %w", &api.PageError{Filename: "2301.pdf", Page: 7, Err: cause})
+	var pageErr *api.PageError
+	assert.ErrorAs(t, err, &pageErr)
+	assert.Equal(t, 7, pageErr.Page)
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, "volume 1: page 7 of 2301.pdf: pdf_page_object not found", err.Error())
+
+	assert.Equal(t, "2301.pdf: pdf_page_object not found", (&api.PageError{Filename: "2301.pdf", Err: cause}).Error())
+}
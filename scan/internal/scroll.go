@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"bytes"
+	"cmp"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	"github.com/chooban/progger/scan/api"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	defaultScrollWidth = 1080
+	// scrollMaxHeight keeps strips small enough for phones to decode comfortably
+	scrollMaxHeight = 12000
+	// scrollPageWidth is the width, in points, of the pages of a scrolling PDF
+	scrollPageWidth = 360.0
+)
+
+var scrollHeaderColour = color.Gray{Y: 0x20}
+
+// scrollWidth is the width pages are scaled to, falling back to the default
+func scrollWidth(options api.ScrollOptions) int {
+	return cmp.Or(options.Width, defaultScrollWidth)
+}
+
+// scaledHeight is the height of an image once scaled to the given width, keeping its aspect ratio
+func scaledHeight(img PageImage, width int) int {
+	if img.Width == 0 {
+		return 0
+	}
+	return max(1, int(float64(img.Height)*float64(width)/float64(img.Width)+0.5))
+}
+
+// scrollHeader is the height of the title band across the top of an episode's first strip
+func scrollHeader(width int) int {
+	return width / 10
+}
+
+// scrollHeights are the heights of the images once scaled to the given width
+func scrollHeights(images []PageImage, width int) []int {
+	heights := make([]int, 0, len(images))
+	for _, img := range images {
+		heights = append(heights, scaledHeight(img, width))
+	}
+	return heights
+}
+
+// scrollStrips groups pages, by their scaled heights, into strips no taller than the limit. The first
+// strip also holds the header. A page taller than the limit gets a strip to itself.
+func scrollStrips(heights []int, header, limit int) [][]int {
+	strips := make([][]int, 0, 1)
+	strip := make([]int, 0)
+	height := header
+	for i, h := range heights {
+		if len(strip) > 0 && height+h > limit {
+			strips = append(strips, strip)
+			strip = make([]int, 0)
+			height = 0
+		}
+		strip = append(strip, i)
+		height += h
+	}
+	if len(strip) > 0 {
+		strips = append(strips, strip)
+	}
+	return strips
+}
+
+// stitchImages stacks an episode's page images into tall strips, scaled to the scroll width, with its title
+// across the top of the first. The strips are encoded at the profile's quality, and in grey if it asks,
+// with what that saves on the pages they were stitched from added to the savings.
+func stitchImages(images []PageImage, title string, options api.ScrollOptions, profile api.ExportProfile, savings *api.ImageSavings) ([]PageImage, error) {
+	width := scrollWidth(options)
+	header := scrollHeader(width)
+	heights := scrollHeights(images, width)
+
+	stitched := make([]PageImage, 0, 1)
+	for s, strip := range scrollStrips(heights, header, scrollMaxHeight) {
+		top := 0
+		if s == 0 {
+			top = header
+		}
+		height := top
+		for _, i := range strip {
+			height += heights[i]
+		}
+
+		var canvas draw.Image
+		if profile.Greyscale {
+			canvas = image.NewGray(image.Rect(0, 0, width, height))
+		} else {
+			canvas = image.NewRGBA(image.Rect(0, 0, width, height))
+		}
+		if s == 0 {
+			drawScrollHeader(canvas, image.Rect(0, 0, width, header), title)
+		}
+
+		y, original := top, 0
+		for _, i := range strip {
+			original += len(images[i].Data)
+			// Pages are decoded one at a time, as a whole episode of decoded pages could be very large
+			decoded, _, err := image.Decode(bytes.NewReader(images[i].Data))
+			if err != nil {
+				return nil, err
+			}
+			draw.CatmullRom.Scale(canvas, image.Rect(0, y, width, y+heights[i]), decoded, decoded.Bounds(), draw.Src, nil)
+			y += heights[i]
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: cmp.Or(profile.Quality, jpegQuality)}); err != nil {
+			return nil, err
+		}
+		if !profile.IsOriginal() {
+			addSavings(savings, original, buf.Len())
+		}
+		stitched = append(stitched, PageImage{Data: buf.Bytes(), Ext: "jpg", Width: width, Height: height})
+	}
+	return stitched, nil
+}
+
+// drawScrollHeader fills a band with the episode's title, centred in white. The title is drawn in a small
+// bitmap font and scaled up to suit the band.
+func drawScrollHeader(dst draw.Image, band image.Rectangle, title string) {
+	draw.Draw(dst, band, image.NewUniform(scrollHeaderColour), image.Point{}, draw.Src)
+	if title == "" || band.Dy() == 0 {
+		return
+	}
+
+	face := basicfont.Face7x13
+	textWidth := font.MeasureString(face, title).Ceil()
+	text := image.NewGray(image.Rect(0, 0, textWidth, face.Height))
+	draw.Draw(text, text.Bounds(), image.NewUniform(scrollHeaderColour), image.Point{}, draw.Src)
+	drawer := &font.Drawer{
+		Dst:  text,
+		Src:  image.White,
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	drawer.DrawString(title)
+
+	scale := min(float64(band.Dy())*0.5/float64(face.Height), float64(band.Dx())*0.9/float64(textWidth))
+	w, h := int(float64(textWidth)*scale), int(float64(face.Height)*scale)
+	x := band.Min.X + (band.Dx()-w)/2
+	y := band.Min.Y + (band.Dy()-h)/2
+	draw.NearestNeighbor.Scale(dst, image.Rect(x, y, x+w, y+h), text, text.Bounds(), draw.Src, nil)
+}
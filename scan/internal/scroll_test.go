@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrollStrips(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		heights  []int
+		header   int
+		limit    int
+		expected [][]int
+	}{
+		{
+			name:     "Everything fits",
+			heights:  []int{100, 100, 100},
+			header:   10,
+			limit:    400,
+			expected: [][]int{{0, 1, 2}},
+		},
+		{
+			name:     "Header pushes the last page on",
+			heights:  []int{100, 100, 100},
+			header:   10,
+			limit:    300,
+			expected: [][]int{{0, 1}, {2}},
+		},
+		{
+			name:     "Page taller than the limit",
+			heights:  []int{100, 500, 100},
+			header:   0,
+			limit:    300,
+			expected: [][]int{{0}, {1}, {2}},
+		},
+		{
+			name:     "No pages",
+			heights:  []int{},
+			header:   10,
+			limit:    300,
+			expected: [][]int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, scrollStrips(tc.heights, tc.header, tc.limit))
+		})
+	}
+}
+
+func TestStitchImages(t *testing.T) {
+	t.Parallel()
+	page := func() PageImage {
+		img := image.NewGray(image.Rect(0, 0, 20, 30))
+		for y := 0; y < 30; y++ {
+			for x := 0; x < 20; x++ {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+		var buf bytes.Buffer
+		assert.NoError(t, jpeg.Encode(&buf, img, nil))
+		return PageImage{Data: buf.Bytes(), Ext: "jpg", Width: 20, Height: 30}
+	}
+	pages := make([]PageImage, 0, 50)
+	for range 50 {
+		pages = append(pages, page())
+	}
+
+	// At 200 pixels wide each page is 300 tall, under a 20 pixel header, so 39 fit in the first strip
+	strips, err := stitchImages(pages, "Hate Box - Part 1", api.ScrollOptions{Enabled: true, Width: 200}, api.ExportProfile{}, &api.ImageSavings{})
+	assert.NoError(t, err)
+	assert.Len(t, strips, 2)
+	assert.Equal(t, 200, strips[0].Width)
+	assert.Equal(t, 20+39*300, strips[0].Height)
+	assert.Equal(t, 11*300, strips[1].Height)
+
+	first, err := jpeg.Decode(bytes.NewReader(strips[0].Data))
+	assert.NoError(t, err)
+	assert.Less(t, color.GrayModel.Convert(first.At(2, 2)).(color.Gray).Y, uint8(64), "header should be dark")
+	assert.Greater(t, color.GrayModel.Convert(first.At(100, 200)).(color.Gray).Y, uint8(235), "page should be white")
+
+	second, err := jpeg.Decode(bytes.NewReader(strips[1].Data))
+	assert.NoError(t, err)
+	assert.Greater(t, color.GrayModel.Convert(second.At(2, 2)).(color.Gray).Y, uint8(235), "later strips have no header")
+}
+
+func TestStitchImagesProfile(t *testing.T) {
+	t.Parallel()
+	img := image.NewRGBA(image.Rect(0, 0, 20, 30))
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, img, nil))
+	pages := []PageImage{
+		{Data: buf.Bytes(), Ext: "jpg", Width: 20, Height: 30},
+		{Data: buf.Bytes(), Ext: "jpg", Width: 20, Height: 30},
+	}
+
+	savings := api.ImageSavings{}
+	profile := api.ExportProfile{Name: "Phone", Quality: 60, Greyscale: true}
+	strips, err := stitchImages(pages, "Hate Box - Part 1", api.ScrollOptions{Enabled: true, Width: 200}, profile, &savings)
+	assert.NoError(t, err)
+	assert.Len(t, strips, 1)
+	assert.Equal(t, 1, savings.Images)
+	assert.Equal(t, int64(2*buf.Len()), savings.OriginalBytes)
+	assert.Equal(t, int64(len(strips[0].Data)), savings.ExportedBytes)
+
+	decoded, err := jpeg.Decode(bytes.NewReader(strips[0].Data))
+	assert.NoError(t, err)
+	assert.Equal(t, color.GrayModel, decoded.ColorModel())
+
+	// The original profile saves nothing, so there's nothing to count
+	savings = api.ImageSavings{}
+	_, err = stitchImages(pages, "Hate Box - Part 1", api.ScrollOptions{Enabled: true, Width: 200}, api.ExportProfile{}, &savings)
+	assert.NoError(t, err)
+	assert.Equal(t, api.ImageSavings{}, savings)
+}
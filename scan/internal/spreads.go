@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"slices"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// spreadDPI is the resolution pages are rendered at when looking for spreads
+	spreadDPI = 50
+	// spreadSizeTolerance is how far apart, as a fraction, the sizes of the two halves of a spread may be
+	spreadSizeTolerance = 0.02
+	// spreadWhite is the grey level above which a pixel counts as blank paper
+	spreadWhite = 240
+	// spreadMinInk is the fraction of each inner edge that must be artwork rather than margin
+	spreadMinInk = 0.3
+	// spreadMinDetail is how much the grey level must vary along an inner edge. Edges of a single flat colour
+	// match each other whether they are a spread or not.
+	spreadMinDetail = 12.0
+	// spreadSeamRatio and spreadSeamSlack limit how much more the two sides of the gutter may differ than
+	// neighbouring columns on the same page do
+	spreadSeamRatio = 1.5
+	spreadSeamSlack = 10.0
+)
+
+// layoutPages works out the pages of an episode as they appear in the export. Each entry is made from one
+// source page, or from two when a spread is joined. Spreads are only looked for if the options ask for them
+// to be joined.
+func layoutPages(instance pdfium.Pdfium, document references.FPDF_DOCUMENT, episode api.ExportPage, pages []int, options api.SpreadOptions) ([][]int, []api.JoinedSpread) {
+	spreads := make([]int, 0)
+	if options.Join {
+		spreads = spreadPairs(pages, func(left, right int) bool {
+			return pagesLookLikeSpread(instance, document, left, right)
+		})
+	}
+	joined := make([]api.JoinedSpread, 0, len(spreads))
+	for _, s := range spreads {
+		joined = append(joined, api.JoinedSpread{Filename: episode.Filename, IssueNumber: episode.IssueNumber, Page: s})
+	}
+	return spreadLayout(pages, spreads, options.KeepOriginals), joined
+}
+
+// spreadPairs returns the pages that start a spread. A spread starts on an even, left-hand, page and ends on
+// the page after it, and both pages must be in the export.
+func spreadPairs(pages []int, isSpread func(left, right int) bool) []int {
+	spreads := make([]int, 0)
+	for i := 0; i < len(pages)-1; i++ {
+		left, right := pages[i], pages[i+1]
+		if left%2 != 0 || right != left+1 {
+			continue
+		}
+		if isSpread(left, right) {
+			spreads = append(spreads, left)
+			i++
+		}
+	}
+	return spreads
+}
+
+// spreadLayout lays out the pages with each spread joined, followed by its two halves if they're kept
+func spreadLayout(pages []int, spreads []int, keepOriginals bool) [][]int {
+	layout := make([][]int, 0, len(pages))
+	for i := 0; i < len(pages); i++ {
+		if !slices.Contains(spreads, pages[i]) || i == len(pages)-1 {
+			layout = append(layout, []int{pages[i]})
+			continue
+		}
+		layout = append(layout, []int{pages[i], pages[i+1]})
+		if keepOriginals {
+			layout = append(layout, []int{pages[i]}, []int{pages[i+1]})
+		}
+		i++
+	}
+	return layout
+}
+
+// pagesLookLikeSpread renders two pages of a document and compares their inner edges. A page that can't be
+// rendered isn't part of a spread.
+func pagesLookLikeSpread(instance pdfium.Pdfium, document references.FPDF_DOCUMENT, left, right int) bool {
+	render := func(pageNum int) (image.Image, func(), error) {
+		rendered, err := instance.RenderPageInDPI(&requests.RenderPageInDPI{
+			Page: requests.Page{ByIndex: &requests.PageByIndex{Document: document, Index: pageNum - 1}},
+			DPI:  spreadDPI,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup := func() {}
+		if rendered.CleanupFunc != nil {
+			cleanup = rendered.CleanupFunc
+		}
+		return rendered.Result.Image, cleanup, nil
+	}
+
+	leftImage, cleanupLeft, err := render(left)
+	if err != nil {
+		return false
+	}
+	defer cleanupLeft()
+	rightImage, cleanupRight, err := render(right)
+	if err != nil {
+		return false
+	}
+	defer cleanupRight()
+	return looksLikeSpread(leftImage, rightImage)
+}
+
+// looksLikeSpread decides whether two pages are the halves of a spread. The pages must be the same size, the
+// artwork must run into the gutter on both, and the columns either side of the gutter must carry on from
+// one another about as smoothly as neighbouring columns on the same page do.
+func looksLikeSpread(left, right image.Image) bool {
+	lb, rb := left.Bounds(), right.Bounds()
+	if !similarSize(lb.Dx(), rb.Dx()) || !similarSize(lb.Dy(), rb.Dy()) || lb.Dx() < 2 || rb.Dx() < 2 {
+		return false
+	}
+
+	rows := min(lb.Dy(), rb.Dy())
+	leftEdge, leftInner := greyColumn(left, lb.Max.X-1, rows), greyColumn(left, lb.Max.X-2, rows)
+	rightEdge, rightInner := greyColumn(right, rb.Min.X, rows), greyColumn(right, rb.Min.X+1, rows)
+
+	if inked(leftEdge) < spreadMinInk || inked(rightEdge) < spreadMinInk {
+		return false
+	}
+	if detail(leftEdge) < spreadMinDetail || detail(rightEdge) < spreadMinDetail {
+		return false
+	}
+	within := (meanDifference(leftEdge, leftInner) + meanDifference(rightEdge, rightInner)) / 2
+	return meanDifference(leftEdge, rightEdge) <= within*spreadSeamRatio+spreadSeamSlack
+}
+
+func similarSize(a, b int) bool {
+	return math.Abs(float64(a-b)) <= float64(max(a, b))*spreadSizeTolerance
+}
+
+// greyColumn returns the grey level of each of the first rows of a column of pixels
+func greyColumn(img image.Image, x, rows int) []float64 {
+	bounds := img.Bounds()
+	column := make([]float64, rows)
+	for i := range column {
+		column[i] = float64(color.GrayModel.Convert(img.At(x, bounds.Min.Y+i)).(color.Gray).Y)
+	}
+	return column
+}
+
+// inked is the fraction of the pixels that aren't blank paper
+func inked(column []float64) float64 {
+	count := 0
+	for _, v := range column {
+		if v < spreadWhite {
+			count++
+		}
+	}
+	return float64(count) / float64(len(column))
+}
+
+// detail is the standard deviation of the grey levels
+func detail(column []float64) float64 {
+	mean := 0.0
+	for _, v := range column {
+		mean += v
+	}
+	mean /= float64(len(column))
+	variance := 0.0
+	for _, v := range column {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(column)))
+}
+
+func meanDifference(a, b []float64) float64 {
+	total := 0.0
+	for i := range a {
+		total += math.Abs(a[i] - b[i])
+	}
+	return total / float64(len(a))
+}
+
+// joinImages puts the two halves of a spread side by side. The right-hand half is scaled to the height of
+// the left if they differ.
+func joinImages(left, right PageImage) (PageImage, error) {
+	leftImage, _, err := image.Decode(bytes.NewReader(left.Data))
+	if err != nil {
+		return PageImage{}, err
+	}
+	rightImage, _, err := image.Decode(bytes.NewReader(right.Data))
+	if err != nil {
+		return PageImage{}, err
+	}
+
+	lb, rb := leftImage.Bounds(), rightImage.Bounds()
+	height := lb.Dy()
+	rightWidth := rb.Dx()
+	if rb.Dy() != height {
+		rightWidth = max(1, int(float64(rb.Dx())*float64(height)/float64(rb.Dy())+0.5))
+	}
+
+	joined := image.NewRGBA(image.Rect(0, 0, lb.Dx()+rightWidth, height))
+	draw.Draw(joined, image.Rect(0, 0, lb.Dx(), height), leftImage, lb.Min, draw.Src)
+	rightRect := image.Rect(lb.Dx(), 0, lb.Dx()+rightWidth, height)
+	if rb.Dy() == height {
+		draw.Draw(joined, rightRect, rightImage, rb.Min, draw.Src)
+	} else {
+		draw.CatmullRom.Scale(joined, rightRect, rightImage, rb, draw.Src, nil)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, joined, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return PageImage{}, err
+	}
+	return PageImage{Data: buf.Bytes(), Ext: "jpg", Width: joined.Bounds().Dx(), Height: height}, nil
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// artwork draws part of a picture that varies smoothly across the page, as a painted spread would
+func artwork(width, height, offsetX int, phase float64) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := 120 + 100*math.Sin(float64(y)/7+float64(x+offsetX)/11+phase)
+			img.SetGray(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+	return img
+}
+
+func blank(width, height int, level uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: level})
+		}
+	}
+	return img
+}
+
+func TestLooksLikeSpread(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		left     image.Image
+		right    image.Image
+		expected bool
+	}{
+		{
+			name:     "Halves of one picture",
+			left:     artwork(80, 120, 0, 0),
+			right:    artwork(80, 120, 80, 0),
+			expected: true,
+		},
+		{
+			name:     "Unrelated pictures",
+			left:     artwork(80, 120, 0, 0),
+			right:    artwork(80, 120, 0, 2.5),
+			expected: false,
+		},
+		{
+			name:     "White margins",
+			left:     blank(80, 120, 255),
+			right:    blank(80, 120, 255),
+			expected: false,
+		},
+		{
+			name:     "Flat colour at the gutter",
+			left:     blank(80, 120, 0),
+			right:    blank(80, 120, 0),
+			expected: false,
+		},
+		{
+			name:     "Different sizes",
+			left:     artwork(80, 120, 0, 0),
+			right:    artwork(120, 120, 80, 0),
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, looksLikeSpread(tc.left, tc.right))
+		})
+	}
+}
+
+func TestSpreadPairs(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		pages    []int
+		spreads  []int
+		expected []int
+	}{
+		{
+			name:     "Spread starting on an even page",
+			pages:    []int{3, 4, 5, 6, 7},
+			spreads:  []int{4},
+			expected: []int{4},
+		},
+		{
+			name:     "Odd pages are right-hand pages",
+			pages:    []int{3, 4, 5, 6},
+			spreads:  []int{3, 5},
+			expected: []int{},
+		},
+		{
+			name:     "Right-hand half filtered out",
+			pages:    []int{4, 6, 7},
+			spreads:  []int{4, 6},
+			expected: []int{6},
+		},
+		{
+			name:     "Consecutive spreads",
+			pages:    []int{2, 3, 4, 5},
+			spreads:  []int{2, 4},
+			expected: []int{2, 4},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			isSpread := func(left, right int) bool { return slices.Contains(tc.spreads, left) }
+			assert.Equal(t, tc.expected, spreadPairs(tc.pages, isSpread))
+		})
+	}
+}
+
+func TestSpreadLayout(t *testing.T) {
+	t.Parallel()
+	pages := []int{3, 4, 5, 6}
+
+	assert.Equal(t, [][]int{{3}, {4}, {5}, {6}}, spreadLayout(pages, []int{}, false))
+	assert.Equal(t, [][]int{{3}, {4, 5}, {6}}, spreadLayout(pages, []int{4}, false))
+	assert.Equal(t, [][]int{{3}, {4, 5}, {4}, {5}, {6}}, spreadLayout(pages, []int{4}, true))
+}
+
+func TestJoinImages(t *testing.T) {
+	t.Parallel()
+	encode := func(img image.Image) PageImage {
+		var buf bytes.Buffer
+		assert.NoError(t, jpeg.Encode(&buf, img, nil))
+		return PageImage{Data: buf.Bytes(), Ext: "jpg", Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	}
+
+	joined, err := joinImages(encode(blank(80, 120, 0)), encode(blank(40, 60, 255)))
+	assert.NoError(t, err)
+	assert.Equal(t, 160, joined.Width)
+	assert.Equal(t, 120, joined.Height)
+
+	decoded, err := jpeg.Decode(bytes.NewReader(joined.Data))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 160, 120), decoded.Bounds())
+	assert.Less(t, color.GrayModel.Convert(decoded.At(20, 60)).(color.Gray).Y, uint8(20))
+	assert.Greater(t, color.GrayModel.Convert(decoded.At(140, 60)).(color.Gray).Y, uint8(235))
+}
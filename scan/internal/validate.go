@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chooban/progger/scan/api"
+	pdfApi "github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+const (
+	checkStructure = "structure"
+	checkPageCount = "page count"
+	checkBookmarks = "bookmarks"
+)
+
+// ValidateExport checks a finished PDF: that pdfcpu finds it valid, that it has the pages expected of it, and
+// that its outline is the one that was written, with every bookmark pointing at a page in the document. An
+// expected page count of zero means it isn't known, and the page count isn't checked.
+func ValidateExport(filename string, expectedPages int, expected []pdfcpu.Bookmark) api.ValidationReport {
+	report := api.ValidationReport{
+		File:          filename,
+		ExpectedPages: expectedPages,
+		Problems:      make([]api.ValidationProblem, 0),
+	}
+	problem := func(check, format string, args ...any) {
+		report.Problems = append(report.Problems, api.ValidationProblem{Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if err := pdfApi.ValidateFile(filename, nil); err != nil {
+		problem(checkStructure, "%s", err)
+	}
+
+	pages, err := PageCount(filename)
+	if err != nil {
+		problem(checkPageCount, "could not count pages: %s", err)
+		return report
+	}
+	report.Pages = pages
+	if expectedPages > 0 && pages != expectedPages {
+		problem(checkPageCount, "has %d pages, expected %d", pages, expectedPages)
+	}
+
+	found, err := ReadBookmarks(filename)
+	if err != nil {
+		problem(checkBookmarks, "could not read bookmarks: %s", err)
+		return report
+	}
+	for _, p := range checkOutline(found, expected, pages) {
+		problem(checkBookmarks, "%s", p)
+	}
+	report.Bookmarks = len(flattenOutline(found, ""))
+	return report
+}
+
+// asRead returns the outline as pdfcpu reads it back. When reading, pdfcpu skips down past any level that
+// has only a single bookmark, so an export of one story is read back as its parts.
+func asRead(bookmarks []pdfcpu.Bookmark) []pdfcpu.Bookmark {
+	for len(bookmarks) == 1 {
+		bookmarks = bookmarks[0].Kids
+	}
+	return bookmarks
+}
+
+// outlineTarget is a bookmark with the titles of its parents, so that bookmarks in different places with
+// the same title can be told apart
+type outlineTarget struct {
+	path string
+	page int
+}
+
+func flattenOutline(bookmarks []pdfcpu.Bookmark, parent string) []outlineTarget {
+	targets := make([]outlineTarget, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		path := b.Title
+		if parent != "" {
+			path = parent + " > " + b.Title
+		}
+		targets = append(targets, outlineTarget{path: path, page: b.PageFrom})
+		targets = append(targets, flattenOutline(b.Kids, path)...)
+	}
+	return targets
+}
+
+// checkOutline compares the outline read back from a PDF with the one written to it
+func checkOutline(found, expected []pdfcpu.Bookmark, pageCount int) []string {
+	problems := make([]string, 0)
+	foundTargets := flattenOutline(found, "")
+	expectedTargets := flattenOutline(asRead(expected), "")
+
+	for _, t := range foundTargets {
+		if t.page < 1 || t.page > pageCount {
+			problems = append(problems, fmt.Sprintf("%q points at page %d of %d", t.path, t.page, pageCount))
+		}
+	}
+
+	remaining := make(map[string][]int)
+	for _, t := range foundTargets {
+		remaining[t.path] = append(remaining[t.path], t.page)
+	}
+	for _, t := range expectedTargets {
+		pages := remaining[t.path]
+		if len(pages) == 0 {
+			problems = append(problems, fmt.Sprintf("%q is missing", t.path))
+			continue
+		}
+		if pages[0] != t.page {
+			problems = append(problems, fmt.Sprintf("%q points at page %d, expected %d", t.path, pages[0], t.page))
+		}
+		remaining[t.path] = pages[1:]
+	}
+
+	unexpected := make([]string, 0)
+	for _, t := range foundTargets {
+		if len(remaining[t.path]) > 0 {
+			unexpected = append(unexpected, fmt.Sprintf("%q", t.path))
+			remaining[t.path] = remaining[t.path][1:]
+		}
+	}
+	if len(unexpected) > 0 {
+		problems = append(problems, fmt.Sprintf("unexpected bookmarks %s", strings.Join(unexpected, ", ")))
+	}
+	return problems
+}
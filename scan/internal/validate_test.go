@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOutline(t *testing.T) {
+	t.Parallel()
+	written := []pdfcpu.Bookmark{
+		{Title: "Judge Dredd", PageFrom: 1, Kids: []pdfcpu.Bookmark{
+			{Title: "Part 1", PageFrom: 1},
+			{Title: "Part 2", PageFrom: 7},
+		}},
+		{Title: "Brink", PageFrom: 13, Kids: []pdfcpu.Bookmark{
+			{Title: "Part 1", PageFrom: 13},
+		}},
+	}
+
+	testCases := []struct {
+		name     string
+		found    []pdfcpu.Bookmark
+		expected []pdfcpu.Bookmark
+		pages    int
+		problems []string
+	}{
+		{
+			name:     "Matching outline",
+			found:    written,
+			expected: written,
+			pages:    18,
+			problems: []string{},
+		},
+		{
+			name: "Wrong and out of range pages",
+			found: []pdfcpu.Bookmark{
+				{Title: "Judge Dredd", PageFrom: 1, Kids: []pdfcpu.Bookmark{
+					{Title: "Part 1", PageFrom: 1},
+					{Title: "Part 2", PageFrom: 8},
+				}},
+				{Title: "Brink", PageFrom: 13, Kids: []pdfcpu.Bookmark{
+					{Title: "Part 1", PageFrom: 20},
+				}},
+			},
+			expected: written,
+			pages:    18,
+			problems: []string{
+				`"Brink > Part 1" points at page 20 of 18`,
+				`"Judge Dredd > Part 2" points at page 8, expected 7`,
+				`"Brink > Part 1" points at page 20, expected 13`,
+			},
+		},
+		{
+			name: "Missing and unexpected bookmarks",
+			found: []pdfcpu.Bookmark{
+				{Title: "Judge Dredd", PageFrom: 1, Kids: []pdfcpu.Bookmark{
+					{Title: "Part 1", PageFrom: 1},
+				}},
+				{Title: "Anderson", PageFrom: 13},
+			},
+			expected: written,
+			pages:    18,
+			problems: []string{
+				`"Judge Dredd > Part 2" is missing`,
+				`"Brink" is missing`,
+				`"Brink > Part 1" is missing`,
+				`unexpected bookmarks "Anderson"`,
+			},
+		},
+		{
+			name: "Single story read back as its parts",
+			found: []pdfcpu.Bookmark{
+				{Title: "Part 1", PageFrom: 1},
+				{Title: "Part 2", PageFrom: 7},
+			},
+			expected: written[:1],
+			pages:    12,
+			problems: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.problems, checkOutline(tc.found, tc.expected, tc.pages))
+		})
+	}
+}
+
+// blankPdf writes a PDF of empty pages
+func blankPdf(t *testing.T, pages int) string {
+	t.Helper()
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+	}
+	kids := make([]string, 0, pages)
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", i+3))
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << >> >>")
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, 0, len(objects))
+	for i, o := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	filename := filepath.Join(t.TempDir(), "export.pdf")
+	assert.NoError(t, os.WriteFile(filename, buf.Bytes(), 0644))
+	return filename
+}
+
+func TestValidateExport(t *testing.T) {
+	t.Parallel()
+	written := []pdfcpu.Bookmark{
+		{Title: "Judge Dredd", PageFrom: 1},
+		{Title: "Brink", PageFrom: 3},
+	}
+
+	testCases := []struct {
+		name          string
+		bookmarks     []pdfcpu.Bookmark
+		expectedPages int
+		problems      []string
+	}{
+		{
+			name:          "Valid export",
+			bookmarks:     written,
+			expectedPages: 4,
+			problems:      []string{},
+		},
+		{
+			name:          "Wrong page count",
+			bookmarks:     written,
+			expectedPages: 5,
+			problems:      []string{checkPageCount},
+		},
+		{
+			name:          "Unknown page count",
+			bookmarks:     written,
+			expectedPages: 0,
+			problems:      []string{},
+		},
+		{
+			name: "Bookmark pointing at the wrong page",
+			bookmarks: []pdfcpu.Bookmark{
+				{Title: "Judge Dredd", PageFrom: 1},
+				{Title: "Brink", PageFrom: 2},
+			},
+			expectedPages: 4,
+			problems:      []string{checkBookmarks},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			filename := blankPdf(t, 4)
+			assert.NoError(t, ReplaceBookmarks(filename, tc.bookmarks))
+
+			report := ValidateExport(filename, tc.expectedPages, written)
+
+			checks := make([]string, 0, len(report.Problems))
+			for _, p := range report.Problems {
+				checks = append(checks, p.Check)
+			}
+			assert.Equal(t, tc.problems, checks, "%v", report.Problems)
+			assert.Equal(t, 4, report.Pages)
+			assert.Equal(t, tc.expectedPages, report.ExpectedPages)
+			assert.Equal(t, 2, report.Bookmarks)
+		})
+	}
+}
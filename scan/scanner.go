@@ -17,8 +17,10 @@ import (
 
 // Scanner encapsulates scanning configuration and operations
 type Scanner struct {
-	knownSeries []string
-	skipTitles  []string
+	knownSeries         []string
+	skipTitles          []string
+	pageText            bool
+	duplicatePreference DuplicatePreference
 }
 
 // NewScanner creates a new Scanner with the given configuration
@@ -29,23 +31,72 @@ func NewScanner(knownSeries, skipTitles []string) *Scanner {
 	}
 }
 
-// Dir scans the given directory for PDF files and extracts episode details from each file.
+// WithPageText enables or disables extracting the text of every episode page while scanning. The text is
+// returned in api.Issue.Pages and is what an Index is built from.
+func (s *Scanner) WithPageText(enabled bool) *Scanner {
+	s.pageText = enabled
+	return s
+}
+
+// WithDuplicatePreference sets the rule used to pick which file to keep when Dir finds the same issue more
+// than once. The default is PreferNewest.
+func (s *Scanner) WithDuplicatePreference(preference DuplicatePreference) *Scanner {
+	s.duplicatePreference = preference
+	return s
+}
+
+// IssueResult is the outcome of scanning a single file with Scanner.Stream
+type IssueResult struct {
+	Issue api.Issue
+	Err   error
+}
+
+// Dir scans the given directory for PDF files and extracts episode details from each file. Where the same
+// issue is found more than once only the preferred file is returned, with the others listed in its
+// Duplicates.
 func (s *Scanner) Dir(ctx context.Context, dir string, scanCount int) ([]api.Issue, error) {
 	logger := logr.FromContextOrDiscard(ctx)
+
+	results, err := s.Stream(ctx, dir, scanCount)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]api.Issue, 0)
+	for r := range results {
+		if r.Err != nil {
+			logger.Error(r.Err, "Failed to read file", "file", r.Issue.Filename)
+			continue
+		}
+		issues = append(issues, r.Issue)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return s.Finalise(ctx, issues), nil
+}
+
+// Stream scans the given directory for PDF files, sending each issue on the returned channel as soon as its
+// file has been read. The channel is closed when every file is done, or the context is cancelled.
+//
+// Streamed issues are neither de-duplicated nor sanitised, as both need every issue to hand. Collect them
+// and pass them to Finalise once the channel is closed.
+func (s *Scanner) Stream(ctx context.Context, dir string, scanCount int) (<-chan IssueResult, error) {
+	logger := logr.FromContextOrDiscard(ctx)
 	logger.Info("Scanning directory", "dir", dir)
 
 	files, err := getFiles(dir)
 	if err != nil {
 		return nil, fmt.Errorf("getting files: %w", err)
 	}
-
-	if len(files) == 0 {
-		return []api.Issue{}, nil
+	if scanCount > 0 && len(files) > scanCount {
+		files = files[:scanCount]
 	}
 	logger.Info("Found files to scan", "num_files", len(files))
 
 	jobs := make(chan string, 10)
-	results := make(chan api.Issue, len(files))
+	results := make(chan IssueResult, 10)
 
 	var wg sync.WaitGroup
 
@@ -57,29 +108,35 @@ func (s *Scanner) Dir(ctx context.Context, dir string, scanCount int) ([]api.Iss
 		go s.scanWorker(ctx, &wg, jobs, results)
 	}
 
-	for i, file := range files {
-		if scanCount > 0 && i >= scanCount {
-			break
+	go func() {
+		defer close(jobs)
+		for _, file := range files {
+			logger.V(1).Info("Adding file to jobs", "file_name", file.Name())
+			select {
+			case jobs <- dir + string(os.PathSeparator) + file.Name():
+			case <-ctx.Done():
+				return
+			}
 		}
-		logger.V(1).Info("Adding file to jobs", "file_name", file.Name())
-		jobs <- dir + string(os.PathSeparator) + file.Name()
-	}
+	}()
 
-	close(jobs)
-	wg.Wait()
-	close(results)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-	issues := make([]api.Issue, 0, len(files))
-	for v := range results {
-		if v.IssueNumber != 0 {
-			issues = append(issues, v)
-		}
-	}
+	return results, nil
+}
+
+// Finalise does the work that needs every issue from a scan: dropping duplicate issues, so that episodes
+// aren't counted twice, and sanitising series and episode titles.
+func (s *Scanner) Finalise(ctx context.Context, issues []api.Issue) []api.Issue {
+	logger := logr.FromContextOrDiscard(ctx)
 
-	// Sanitise the results to correct titles
+	issues = removeDuplicates(logger, issues, s.duplicatePreference)
 	Sanitise(ctx, &issues, s.knownSeries)
 
-	return issues, nil
+	return issues
 }
 
 // File scans a single PDF file and extracts episode details.
@@ -103,24 +160,71 @@ func (s *Scanner) File(ctx context.Context, fileName string) (api.Issue, error)
 		} else {
 			logger.V(1).Info("Failed to extract credits", "file", fileName)
 		}
+		// Reprints often say where they were first published on their opening page
+		if text, err := p.PageText(fileName, details.Bookmark.PageFrom, details.Bookmark.PageFrom); err == nil && len(text) > 0 {
+			episodeDetails[i].FirstPageText = text[0]
+		}
 	}
 
 	issue := internal.BuildIssue(logger, fileName, episodeDetails, s.knownSeries, s.skipTitles)
 
+	if s.pageText {
+		issue.Pages = readPageText(logger, p, fileName, issue.Episodes)
+	}
+
+	if hash, err := hashFile(fileName); err == nil {
+		issue.Hash = hash
+	} else {
+		logger.V(1).Info("Failed to hash file", "file", fileName)
+	}
+
 	return issue, nil
 }
 
-func (s *Scanner) scanWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan string, results chan<- api.Issue) {
+// readPageText extracts the text of every page covered by the given episodes. Pages which can't be read
+// are logged and left out.
+func readPageText(logger logr.Logger, p *internal.Reader, fileName string, episodes []*api.Episode) []api.PageText {
+	pages := make([]api.PageText, 0)
+	seen := make(map[int]bool)
+	for _, e := range episodes {
+		text, err := p.PageText(fileName, e.FirstPage, e.LastPage)
+		if err != nil {
+			logger.V(1).Info("Failed to extract page text", "file", fileName, "series", e.Series)
+			continue
+		}
+		for i, t := range text {
+			pageNumber := e.FirstPage + i
+			if seen[pageNumber] {
+				continue
+			}
+			seen[pageNumber] = true
+			pages = append(pages, api.PageText{Page: pageNumber, Text: t})
+		}
+	}
+	return pages
+}
+
+func (s *Scanner) scanWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan string, results chan<- IssueResult) {
 	logger := logr.FromContextOrDiscard(ctx)
 	logger.V(1).Info("Creating worker")
 	defer wg.Done()
 
 	for j := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
 		issue, err := s.File(ctx, j)
+		if err == nil && issue.IssueNumber == 0 {
+			// Not something we could identify as an issue, and already logged
+			continue
+		}
 		if err != nil {
-			logger.Error(err, "Failed to read file", "file", j)
+			issue.Filename = j
+		}
+		select {
+		case results <- IssueResult{Issue: issue, Err: err}:
+		case <-ctx.Done():
 		}
-		results <- issue
 	}
 	logger.V(1).Info("Shutting down worker")
 }
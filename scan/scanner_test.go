@@ -0,0 +1,62 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanner_StreamNoFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a prog"), 0644))
+
+	results, err := NewScanner([]string{}, []string{}).Stream(context.Background(), dir, 0)
+	assert.NoError(t, err)
+
+	count := 0
+	for range results {
+		count++
+	}
+	assert.Equal(t, 0, count)
+}
+
+func TestScanner_StreamMissingDir(t *testing.T) {
+	t.Parallel()
+	_, err := NewScanner([]string{}, []string{}).Stream(context.Background(), filepath.Join(t.TempDir(), "missing"), 0)
+	assert.Error(t, err)
+}
+
+func TestScanner_Finalise(t *testing.T) {
+	t.Parallel()
+	issues := []api.Issue{
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2300,
+			Filename:    "a.pdf",
+			Episodes:    []*api.Episode{{Series: "Judge Dredd", Title: "Get Sin", Part: 1}},
+		},
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2300,
+			Filename:    "b.pdf",
+			Episodes:    []*api.Episode{{Series: "Judge Dredd", Title: "Get Sin", Part: 1}},
+		},
+		{
+			Publication: "2000 AD",
+			IssueNumber: 2301,
+			Filename:    "c.pdf",
+			Episodes:    []*api.Episode{{Series: "Dexter", Title: "Get Sin", Part: 2}},
+		},
+	}
+
+	finalised := NewScanner([]string{}, []string{}).Finalise(context.Background(), issues)
+
+	assert.Len(t, finalised, 2)
+	assert.Equal(t, []string{"b.pdf"}, finalised[0].Duplicates)
+	assert.Equal(t, "Sinister Dexter", finalised[1].Episodes[0].Series)
+}
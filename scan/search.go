@@ -0,0 +1,260 @@
+package scan
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chooban/progger/scan/api"
+)
+
+// snippetRadius is the number of characters either side of a match included in a search snippet.
+const snippetRadius = 60
+
+// Index is an in-memory full-text index over the page text of scanned issues. Issues must be scanned with
+// Scanner.WithPageText enabled for there to be anything to index.
+type Index struct {
+	mu        sync.RWMutex
+	documents []indexedPage
+	postings  map[string][]int
+}
+
+type indexedPage struct {
+	Publication string
+	IssueNumber int
+	Filename    string
+	Series      string
+	Title       string
+	Part        int
+	Page        int
+	Text        string
+}
+
+// NewIndex creates an empty Index
+func NewIndex() *Index {
+	return &Index{
+		documents: make([]indexedPage, 0),
+		postings:  make(map[string][]int),
+	}
+}
+
+// Add indexes the page text of an issue. Only pages belonging to an episode are indexed. Adding an issue
+// from a file that has already been indexed replaces the earlier entries.
+func (idx *Index) Add(issue api.Issue) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if slices.ContainsFunc(idx.documents, func(d indexedPage) bool { return d.Filename == issue.Filename }) {
+		idx.documents = slices.DeleteFunc(idx.documents, func(d indexedPage) bool {
+			return d.Filename == issue.Filename
+		})
+		idx.rebuild()
+	}
+
+	for _, page := range issue.Pages {
+		episodeIdx := slices.IndexFunc(issue.Episodes, func(e *api.Episode) bool {
+			return page.Page >= e.FirstPage && page.Page <= e.LastPage
+		})
+		if episodeIdx < 0 || strings.TrimSpace(page.Text) == "" {
+			continue
+		}
+		episode := issue.Episodes[episodeIdx]
+		idx.documents = append(idx.documents, indexedPage{
+			Publication: issue.Publication,
+			IssueNumber: issue.IssueNumber,
+			Filename:    issue.Filename,
+			Series:      episode.Series,
+			Title:       episode.Title,
+			Part:        episode.Part,
+			Page:        page.Page,
+			Text:        page.Text,
+		})
+		idx.addPostings(len(idx.documents) - 1)
+	}
+}
+
+// Len returns the number of indexed pages
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.documents)
+}
+
+// Search returns the pages containing every word in the query, best matches first. Pages containing the
+// query as a phrase rank above those that merely contain all the words. A limit of zero or less returns
+// every hit.
+func (idx *Index) Search(query string, limit int) []api.SearchHit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	terms := tokenise(query)
+	if len(terms) == 0 {
+		return []api.SearchHit{}
+	}
+
+	var matches []int
+	for i, term := range terms {
+		docs, ok := idx.postings[term]
+		if !ok {
+			return []api.SearchHit{}
+		}
+		if i == 0 {
+			matches = slices.Clone(docs)
+			continue
+		}
+		matches = slices.DeleteFunc(matches, func(d int) bool {
+			_, found := slices.BinarySearch(docs, d)
+			return !found
+		})
+	}
+
+	phrase := strings.Join(terms, " ")
+	hits := make([]api.SearchHit, 0, len(matches))
+	for _, d := range matches {
+		doc := idx.documents[d]
+		words := tokenise(doc.Text)
+
+		score := 0
+		for _, w := range words {
+			if slices.Contains(terms, w) {
+				score++
+			}
+		}
+		if len(terms) > 1 && strings.Contains(strings.Join(words, " "), phrase) {
+			score += 10 * len(terms)
+		}
+
+		hits = append(hits, api.SearchHit{
+			Publication: doc.Publication,
+			IssueNumber: doc.IssueNumber,
+			Filename:    doc.Filename,
+			Series:      doc.Series,
+			Title:       doc.Title,
+			Part:        doc.Part,
+			Page:        doc.Page,
+			Snippet:     snippet(doc.Text, terms),
+			Score:       score,
+		})
+	}
+
+	slices.SortFunc(hits, func(a, b api.SearchHit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.IssueNumber, b.IssueNumber); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Page, b.Page)
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// MarshalJSON writes out the indexed pages. The postings are rebuilt when the index is read back in.
+func (idx *Index) MarshalJSON() ([]byte, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return json.Marshal(idx.documents)
+}
+
+func (idx *Index) UnmarshalJSON(data []byte) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	documents := make([]indexedPage, 0)
+	if err := json.Unmarshal(data, &documents); err != nil {
+		return err
+	}
+	idx.documents = documents
+	idx.rebuild()
+
+	return nil
+}
+
+func (idx *Index) rebuild() {
+	idx.postings = make(map[string][]int)
+	for i := range idx.documents {
+		idx.addPostings(i)
+	}
+}
+
+func (idx *Index) addPostings(docId int) {
+	if idx.postings == nil {
+		idx.postings = make(map[string][]int)
+	}
+	for _, term := range tokenise(idx.documents[docId].Text) {
+		docs := idx.postings[term]
+		if len(docs) > 0 && docs[len(docs)-1] == docId {
+			continue
+		}
+		idx.postings[term] = append(docs, docId)
+	}
+}
+
+// tokenise lower cases the input and splits it into words. Apostrophes are dropped so that "Dredd's" and
+// "dredds" match, which suits lettering that is often inconsistent about them.
+func tokenise(input string) []string {
+	input = strings.Map(dropApostrophe, strings.ToLower(input))
+	return strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// dropApostrophe is a mapping for strings.Map that removes apostrophes, straight or curly
+func dropApostrophe(r rune) rune {
+	if r == '\'' || r == '’' {
+		return -1
+	}
+	return r
+}
+
+// snippet returns the text surrounding the first occurrence of any of the terms, with whitespace collapsed.
+// The terms are looked for with apostrophes dropped, as they were when the text was indexed.
+func snippet(text string, terms []string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+
+	// searched is the text as it was tokenised, and at maps each of its runes back to the text's
+	searched := make([]rune, 0, len(runes))
+	at := make([]int, 0, len(runes))
+	for i, r := range runes {
+		if dropApostrophe(r) < 0 {
+			continue
+		}
+		searched = append(searched, unicode.ToLower(r))
+		at = append(at, i)
+	}
+	lower := string(searched)
+
+	runeStart := -1
+	for _, term := range terms {
+		i := strings.Index(lower, term)
+		if i < 0 || term == "" {
+			continue
+		}
+		if r := at[utf8.RuneCountInString(lower[:i])]; runeStart < 0 || r < runeStart {
+			runeStart = r
+		}
+	}
+	runeStart = max(0, runeStart)
+	from := max(0, runeStart-snippetRadius)
+	to := min(len(runes), runeStart+snippetRadius)
+
+	s := strings.TrimSpace(string(runes[from:to]))
+	if from > 0 {
+		s = "..." + s
+	}
+	if to < len(runes) {
+		s = s + "..."
+	}
+	return s
+}
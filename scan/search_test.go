@@ -0,0 +1,132 @@
+package scan
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func searchTestIndex() *Index {
+	idx := NewIndex()
+	idx.Add(api.Issue{
+		Publication: "2000 AD",
+		IssueNumber: 2300,
+		Filename:    "2000AD 2300 (1977).pdf",
+		Episodes: []*api.Episode{
+			{Series: "Judge Dredd", Title: "Get Sin", Part: 2, FirstPage: 3, LastPage: 8},
+			{Series: "Brink", Title: "Hate Box", Part: 1, FirstPage: 9, LastPage: 14},
+		},
+		Pages: []api.PageText{
+			{Page: 1, Text: "I am the law, says the cover"},
+			{Page: 4, Text: "STAY WHERE YOU ARE, CREEP!\r\nI AM THE LAW!"},
+			{Page: 5, Text: "The law is the law."},
+			{Page: 10, Text: "Bridget Kurtis didn't ask to be in the hab."},
+		},
+	})
+	return idx
+}
+
+func TestIndex_Search(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name          string
+		query         string
+		expectedPages []int
+	}{
+		{
+			name:          "Phrase ranks first",
+			query:         "I am the law",
+			expectedPages: []int{4},
+		},
+		{
+			name:          "Single word",
+			query:         "law",
+			expectedPages: []int{5, 4},
+		},
+		{
+			name:          "Case and apostrophes ignored",
+			query:         "DIDNT ask",
+			expectedPages: []int{10},
+		},
+		{
+			name:          "No match",
+			query:         "Grud",
+			expectedPages: []int{},
+		},
+		{
+			name:          "Empty query",
+			query:         "  ",
+			expectedPages: []int{},
+		},
+	}
+
+	idx := searchTestIndex()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			hits := idx.Search(tc.query, 0)
+			pages := make([]int, 0, len(hits))
+			for _, h := range hits {
+				pages = append(pages, h.Page)
+			}
+			assert.Equal(t, tc.expectedPages, pages)
+		})
+	}
+}
+
+func TestIndex_SearchHitDetails(t *testing.T) {
+	t.Parallel()
+	hits := searchTestIndex().Search("hab", 1)
+
+	assert.Len(t, hits, 1)
+	assert.Equal(t, "Brink", hits[0].Series)
+	assert.Equal(t, "Hate Box", hits[0].Title)
+	assert.Equal(t, 2300, hits[0].IssueNumber)
+	assert.Equal(t, "Bridget Kurtis didn't ask to be in the hab.", hits[0].Snippet)
+}
+
+func TestIndex_AddReplacesFile(t *testing.T) {
+	t.Parallel()
+	idx := searchTestIndex()
+	assert.Equal(t, 3, idx.Len())
+
+	idx.Add(api.Issue{
+		Filename: "2000AD 2300 (1977).pdf",
+		Episodes: []*api.Episode{{Series: "Judge Dredd", FirstPage: 3, LastPage: 8}},
+		Pages:    []api.PageText{{Page: 3, Text: "Drokk"}},
+	})
+
+	assert.Equal(t, 1, idx.Len())
+	assert.Empty(t, idx.Search("law", 0))
+	assert.Len(t, idx.Search("drokk", 0), 1)
+}
+
+func TestIndex_JSONRoundTrip(t *testing.T) {
+	t.Parallel()
+	data, err := json.Marshal(searchTestIndex())
+	assert.NoError(t, err)
+
+	idx := NewIndex()
+	assert.NoError(t, json.Unmarshal(data, idx))
+	assert.Equal(t, 3, idx.Len())
+	assert.Len(t, idx.Search("creep", 0), 1)
+}
+
+func TestSnippet(t *testing.T) {
+	t.Parallel()
+	text := "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore " +
+		"et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi"
+
+	s := snippet(text, []string{"aliqua"})
+	assert.True(t, len(s) < len(text))
+	assert.Contains(t, s, "aliqua")
+	assert.Equal(t, "...", s[:3])
+	assert.Equal(t, "...", s[len(s)-3:])
+
+	// Apostrophes are dropped from the index, so "thargs" is a hit on "Tharg's"
+	text = "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore " +
+		"et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud Tharg’s exercitation ullamco laboris nisi"
+	assert.Contains(t, snippet(text, tokenise("Tharg's")), "Tharg’s")
+}
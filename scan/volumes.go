@@ -0,0 +1,148 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/chooban/progger/scan/internal"
+	"github.com/go-logr/logr"
+)
+
+// BuildVolumes exports the pages as one or more volumes, each built as Build would with its own bookmarks
+// and metadata. When there is more than one volume, the volume number is added to the file name. If any
+// volume fails, those already built are removed, so that an export is never left half made.
+func BuildVolumes(ctx context.Context, pages []api.ExportPage, options api.BuildOptions, volumes api.VolumeOptions, fileName string) (api.BuildReport, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	split := SplitVolumes(pages, volumes, pageSizeEstimator())
+	report := api.BuildReport{
+		Files:      make([]string, 0, len(split)),
+		Dropped:    make([]api.DroppedPage, 0),
+		Spreads:    make([]api.JoinedSpread, 0),
+		Validation: make([]api.ValidationReport, 0),
+	}
+	for i, volume := range split {
+		volumeName := VolumeFilename(fileName, i+1, len(split))
+		logger.Info("Building volume", "volume", i+1, "of", len(split), "file_name", volumeName)
+
+		r, err := Build(ctx, volume, options, volumeName)
+		addVolumeReport(&report, r)
+		if err != nil {
+			for _, f := range report.Files {
+				if removeErr := os.Remove(f); removeErr != nil {
+					logger.Error(removeErr, "Failed to remove volume", "file_name", f)
+				}
+			}
+			report.Files = report.Files[:0]
+			return report, fmt.Errorf("volume %d: %w", i+1, err)
+		}
+		report.Files = append(report.Files, r.Files...)
+	}
+	return report, nil
+}
+
+// addVolumeReport adds what was found building one volume to the report for the whole export. The files
+// are left out, as they are only added once the volume has been built.
+func addVolumeReport(report *api.BuildReport, volume api.BuildReport) {
+	report.Dropped = append(report.Dropped, volume.Dropped...)
+	report.Savings.Images += volume.Savings.Images
+	report.Savings.OriginalBytes += volume.Savings.OriginalBytes
+	report.Savings.ExportedBytes += volume.Savings.ExportedBytes
+	report.Validation = append(report.Validation, volume.Validation...)
+	report.Spreads = append(report.Spreads, volume.Spreads...)
+	report.BlankPages += volume.BlankPages
+	report.Panels += volume.Panels
+	report.Pages += volume.Pages
+}
+
+// SplitVolumes breaks the pages into volumes. An episode is never split across volumes, so a single
+// episode larger than the limit gets a volume to itself. The size function returns the estimated size in
+// bytes of an episode, and is only used when splitting by size.
+func SplitVolumes(pages []api.ExportPage, options api.VolumeOptions, size func(api.ExportPage) int64) [][]api.ExportPage {
+	if len(pages) == 0 {
+		return [][]api.ExportPage{}
+	}
+
+	switch options.Split {
+	case api.SplitByStory:
+		return splitByStory(pages)
+	case api.SplitByPages:
+		if options.MaxPages > 0 {
+			return splitByLimit(pages, int64(options.MaxPages), func(p api.ExportPage) int64 {
+				return int64(p.PageTo - p.PageFrom + 1)
+			})
+		}
+	case api.SplitBySize:
+		if options.MaxBytes > 0 {
+			return splitByLimit(pages, options.MaxBytes, size)
+		}
+	}
+	return [][]api.ExportPage{pages}
+}
+
+func splitByLimit(pages []api.ExportPage, limit int64, measure func(api.ExportPage) int64) [][]api.ExportPage {
+	volumes := make([][]api.ExportPage, 0)
+	current := make([]api.ExportPage, 0)
+	var total int64
+	for _, p := range pages {
+		m := measure(p)
+		if len(current) > 0 && total+m > limit {
+			volumes = append(volumes, current)
+			current = make([]api.ExportPage, 0)
+			total = 0
+		}
+		current = append(current, p)
+		total += m
+	}
+	return append(volumes, current)
+}
+
+// splitByStory gives each story a volume, in the order the stories first appear. Episodes keep their order
+// within a story.
+func splitByStory(pages []api.ExportPage) [][]api.ExportPage {
+	volumes := make([][]api.ExportPage, 0)
+	index := make(map[string]int)
+	for _, p := range pages {
+		key := p.Series + "\x00" + p.Story
+		i, ok := index[key]
+		if !ok {
+			i = len(volumes)
+			index[key] = i
+			volumes = append(volumes, make([]api.ExportPage, 0))
+		}
+		volumes[i] = append(volumes[i], p)
+	}
+	return volumes
+}
+
+// VolumeFilename numbers a volume's file name, e.g. "Judge Dredd - Vol 02.pdf". Numbers are padded to
+// the same width so that the files sort in order. A single volume keeps the name it was given.
+func VolumeFilename(fileName string, volume, count int) string {
+	if count <= 1 {
+		return fileName
+	}
+	ext := filepath.Ext(fileName)
+	width := max(2, len(fmt.Sprint(count)))
+	return fmt.Sprintf("%s - Vol %0*d%s", strings.TrimSuffix(fileName, ext), width, volume, ext)
+}
+
+// pageSizeEstimator estimates the size of an episode from the average page size of its source file
+func pageSizeEstimator() func(api.ExportPage) int64 {
+	perPage := make(map[string]int64)
+	return func(p api.ExportPage) int64 {
+		bytes, ok := perPage[p.Filename]
+		if !ok {
+			if info, err := os.Stat(p.Filename); err == nil {
+				if count, err := internal.PageCount(p.Filename); err == nil && count > 0 {
+					bytes = info.Size() / int64(count)
+				}
+			}
+			perPage[p.Filename] = bytes
+		}
+		return bytes * int64(p.PageTo-p.PageFrom+1)
+	}
+}
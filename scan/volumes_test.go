@@ -0,0 +1,95 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/chooban/progger/scan/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func volumeTestPages() []api.ExportPage {
+	return []api.ExportPage{
+		{Series: "Judge Dredd", Story: "Get Sin", IssueNumber: 2301, PageFrom: 1, PageTo: 6},
+		{Series: "Brink", Story: "Hate Box", IssueNumber: 2301, PageFrom: 7, PageTo: 12},
+		{Series: "Judge Dredd", Story: "Get Sin", IssueNumber: 2302, PageFrom: 1, PageTo: 8},
+		{Series: "Brink", Story: "Hate Box", IssueNumber: 2302, PageFrom: 9, PageTo: 14},
+	}
+}
+
+func volumeStories(volumes [][]api.ExportPage) [][]string {
+	stories := make([][]string, 0, len(volumes))
+	for _, v := range volumes {
+		vs := make([]string, 0, len(v))
+		for _, p := range v {
+			vs = append(vs, p.Series+" "+p.Story)
+		}
+		stories = append(stories, vs)
+	}
+	return stories
+}
+
+func TestSplitVolumes(t *testing.T) {
+	t.Parallel()
+	size := func(p api.ExportPage) int64 { return int64(p.PageTo-p.PageFrom+1) * 1000 }
+
+	testCases := []struct {
+		name     string
+		options  api.VolumeOptions
+		expected []int
+	}{
+		{name: "No split", options: api.VolumeOptions{}, expected: []int{4}},
+		{name: "By pages", options: api.VolumeOptions{Split: api.SplitByPages, MaxPages: 13}, expected: []int{2, 1, 1}},
+		{name: "Filling to the limit", options: api.VolumeOptions{Split: api.SplitByPages, MaxPages: 14}, expected: []int{2, 2}},
+		{name: "Episode larger than the limit", options: api.VolumeOptions{Split: api.SplitByPages, MaxPages: 4}, expected: []int{1, 1, 1, 1}},
+		{name: "By size", options: api.VolumeOptions{Split: api.SplitBySize, MaxBytes: 20000}, expected: []int{3, 1}},
+		{name: "By pages without a limit", options: api.VolumeOptions{Split: api.SplitByPages}, expected: []int{4}},
+		{name: "By story", options: api.VolumeOptions{Split: api.SplitByStory}, expected: []int{2, 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			volumes := SplitVolumes(volumeTestPages(), tc.options, size)
+			lengths := make([]int, 0, len(volumes))
+			for _, v := range volumes {
+				lengths = append(lengths, len(v))
+			}
+			assert.Equal(t, tc.expected, lengths)
+		})
+	}
+}
+
+func TestSplitVolumes_ByStoryKeepsOrder(t *testing.T) {
+	t.Parallel()
+	volumes := SplitVolumes(volumeTestPages(), api.VolumeOptions{Split: api.SplitByStory}, nil)
+
+	assert.Equal(t, [][]string{
+		{"Judge Dredd Get Sin", "Judge Dredd Get Sin"},
+		{"Brink Hate Box", "Brink Hate Box"},
+	}, volumeStories(volumes))
+	assert.Equal(t, 2302, volumes[0][1].IssueNumber)
+}
+
+func TestVolumeFilename(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "Judge Dredd.pdf", VolumeFilename("Judge Dredd.pdf", 1, 1))
+	assert.Equal(t, "Judge Dredd - Vol 02.pdf", VolumeFilename("Judge Dredd.pdf", 2, 3))
+	assert.Equal(t, "exports/Judge Dredd - Vol 007.cbz", VolumeFilename("exports/Judge Dredd.cbz", 7, 120))
+}
+
+func TestAddVolumeReport(t *testing.T) {
+	t.Parallel()
+	report := api.BuildReport{Dropped: make([]api.DroppedPage, 0)}
+	addVolumeReport(&report, api.BuildReport{
+		Files:   []string{"Judge Dredd 1.pdf"},
+		Dropped: []api.DroppedPage{{Filename: "2000AD 2301 (1977).pdf", Page: 2}},
+		Savings: api.ImageSavings{Images: 10, OriginalBytes: 1000, ExportedBytes: 400},
+	})
+	addVolumeReport(&report, api.BuildReport{
+		Savings: api.ImageSavings{Images: 5, OriginalBytes: 500, ExportedBytes: 300},
+	})
+
+	assert.Empty(t, report.Files)
+	assert.Len(t, report.Dropped, 1)
+	assert.Equal(t, api.ImageSavings{Images: 15, OriginalBytes: 1500, ExportedBytes: 700}, report.Savings)
+}